@@ -17,14 +17,19 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html/template"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -38,7 +43,7 @@ import (
 )
 
 var knownKeysLock = new(sync.Mutex)
-var knownKeys = make(map[string]bool)
+var knownKeys = make(map[string]*announcement)
 
 type announcement struct {
 	Key                    string
@@ -46,21 +51,61 @@ type announcement struct {
 	Plugins                []string
 	UsersSeeErrors         bool
 	UsersCanDeleteMessages bool
+	UsersRequireApproval   bool
+	AuthorSignature        bool
+	PublicArchive          bool
+	Robots                 bool
+	AttachmentMaxSize      int
+	AttachmentExtensions   []string
 	PasswordMethod         string
-	PasswordAdmin          []string
-	PasswordUser           []string
+	PasswordAdmin          []passwordEntry
+	PasswordUser           []passwordEntry
 
-	plugins   []registry.Plugin
-	messages  []templates.AnnouncementMessage
-	notLoaded map[string]string
-	l         *sync.Mutex
+	plugins          []registry.Plugin
+	pluginNames      []string
+	messages         []templates.AnnouncementMessage
+	scheduled        []templates.ScheduledAnnouncement
+	recurring        []templates.RecurringAnnouncement
+	drafts           []templates.Draft
+	review           []templates.Submission
+	expiring         []expiringAnnouncement
+	categories       []string
+	messageTemplates []templates.MessageTemplate
+	notLoaded        map[string]string
+	errors           chan string
+	handles          []string
+	robots           []string
+	cancel           context.CancelFunc
+	pluginCancel     context.CancelFunc
+	l                *sync.Mutex
 }
 
-// LoadAnnouncements loads all announcements in a path.
-// The announcements are loaded in memory and registered to the server. You can not access them directly.
-// This function is not save to be used in parallel.
-// It should be used at the beginning of the lifetime of the application (before the server is started).
-func LoadAnnouncements(path string) error {
+// passwordEntry represents a password of an announcement.
+// In the configuration, it can either be given as a string (only the password) or as an object containing the password and the display name of the person using it.
+type passwordEntry struct {
+	Password string
+	Name     string
+}
+
+func (p *passwordEntry) UnmarshalJSON(b []byte) error {
+	var password string
+	if json.Unmarshal(b, &password) == nil {
+		p.Password = password
+		p.Name = ""
+		return nil
+	}
+	type entry passwordEntry // prevent recursion
+	return json.Unmarshal(b, (*entry)(p))
+}
+
+// LoadAnnouncements loads all announcements in a path and applies them to the running announcements.
+// New keys are added, keys which are not configured any more are stopped and removed together with all their handlers.
+// Keys with a changed configuration are replaced. Their plugins are only restarted if the plugins or the short description changed.
+// Nothing is changed if any configuration is invalid.
+// ctx limits the time to stop the plugins of removed or replaced keys.
+// You can savely use it in parallel.
+func LoadAnnouncements(ctx context.Context, path string) error {
+	loaded := make(map[string]*announcement)
 	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -78,28 +123,74 @@ func LoadAnnouncements(path string) error {
 		if err != nil {
 			return err
 		}
-		err = a.Initialise()
+		err = a.validate()
 		if err != nil {
 			return fmt.Errorf("%s: %w", path, err)
 		}
+		_, ok := loaded[a.Key]
+		if ok {
+			return fmt.Errorf("%s: key already in use", path)
+		}
+		loaded[a.Key] = a
 
 		return nil
 	})
 	if err != nil {
 		return fmt.Errorf("announcement: %w", err)
 	}
-	return nil
-}
 
-func (a *announcement) Initialise() error {
-	a.l = new(sync.Mutex)
-	a.l.Lock()
-	defer a.l.Unlock()
+	knownKeysLock.Lock()
+	defer knownKeysLock.Unlock()
 
-	a.notLoaded = make(map[string]string)
+	for key, old := range knownKeys {
+		_, ok := loaded[key]
+		if ok {
+			continue
+		}
+		old.teardown(ctx)
+		delete(knownKeys, key)
+		log.Println("announcement: removed", key)
+	}
 
-	errorChannel := make(chan string, 100)
+	keys := make([]string, 0, len(loaded))
+	for key := range loaded {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
 
+	var errs []error
+	for _, key := range keys {
+		a := loaded[key]
+		old, ok := knownKeys[key]
+		switch {
+		case !ok:
+			err = a.Initialise()
+		case old.sameConfig(a):
+			continue
+		case old.samePlugins(a):
+			old.detach()
+			err = a.initialise(old)
+		default:
+			old.teardown(ctx)
+			err = a.Initialise()
+		}
+		if err != nil {
+			a.teardown(ctx)
+			delete(knownKeys, key)
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		knownKeys[key] = a
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("announcement: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// validate checks the configuration of the announcement and escapes the key.
+// It does not change anything outside of the announcement.
+func (a *announcement) validate() error {
 	if a.Key == "" {
 		return fmt.Errorf("invalid key")
 	}
@@ -107,47 +198,118 @@ func (a *announcement) Initialise() error {
 		return fmt.Errorf("invalid character in key key")
 	}
 	a.Key = url.PathEscape(a.Key)
-
-	knownKeysLock.Lock()
-	ok := knownKeys[a.Key]
-	if ok {
-		knownKeysLock.Unlock()
-		return fmt.Errorf("key already in use")
+	if a.Key == dashboardKey && dashboardEnabled() {
+		return fmt.Errorf("key %s is reserved for the dashboard", a.Key)
 	}
-	knownKeys[a.Key] = true
-	knownKeysLock.Unlock()
-
-	a.loadErrors()
 
 	if !a.UsersSeeErrors && a.UsersCanDeleteMessages {
 		return fmt.Errorf("users can only delete messages when they can see errors (%s)", a.Key)
 	}
 
-	plugins := make(map[string]bool, len(a.Plugins))
+	if a.Robots && !a.PublicArchive {
+		return fmt.Errorf("robots can only be allowed for a public archive (%s)", a.Key)
+	}
 
-	ok = registry.PasswordMethodExists(a.PasswordMethod)
+	ok := registry.PasswordMethodExists(a.PasswordMethod)
 	if !ok {
 		return fmt.Errorf("password method '%s' not known", a.PasswordMethod)
 	}
 
+	plugins := make(map[string]bool, len(a.Plugins))
 	for i := range a.Plugins {
 		if plugins[a.Plugins[i]] {
-			fmt.Errorf("announcement: plugin %s found twice", a.Plugins[i])
+			return fmt.Errorf("announcement: plugin %s found twice", a.Plugins[i])
 		}
-		pf, ok := registry.GetPlugin(a.Plugins[i])
+		plugins[a.Plugins[i]] = true
+		_, ok := registry.GetPlugin(a.Plugins[i])
 		if !ok {
 			return fmt.Errorf("announcement: unknown plugin %s", a.Plugins[i])
 		}
-		p, err := pf(a.Key, a.ShortDescription, errorChannel)
-		if err != nil {
-			log.Printf("announcement.Initialise: plugin %s has error %s", a.Plugins[i], err.Error())
-			a.notLoaded[a.Plugins[i]] = err.Error()
-			continue
+	}
+	return nil
+}
+
+// sameConfig returns whether both announcements have the same configuration.
+func (a *announcement) sameConfig(b *announcement) bool {
+	ca, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	cb, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(ca, cb)
+}
+
+// samePlugins returns whether the plugins of both announcements are created with the same configuration.
+func (a *announcement) samePlugins(b *announcement) bool {
+	return a.ShortDescription == b.ShortDescription && slices.Equal(a.Plugins, b.Plugins)
+}
+
+// Initialise loads the state of the announcement, creates all plugins, registers all handlers and starts the background workers.
+// The announcement must be validated before.
+func (a *announcement) Initialise() error {
+	return a.initialise(nil)
+}
+
+// initialise works like Initialise. If old is not nil, the running plugins of old are taken over instead of creating new ones.
+// In this case, old must be detached before.
+func (a *announcement) initialise(old *announcement) error {
+	a.l = new(sync.Mutex)
+	a.l.Lock()
+	defer a.l.Unlock()
+
+	a.notLoaded = make(map[string]string)
+
+	a.loadErrors()
+	a.loadInternal("scheduled", &a.scheduled)
+	a.loadInternal("recurring", &a.recurring)
+	a.loadInternal("drafts", &a.drafts)
+	a.loadInternal("review", &a.review)
+	a.loadInternal("expiring", &a.expiring)
+	a.loadInternal("categories", &a.categories)
+	a.loadInternal("templates", &a.messageTemplates)
+	registry.SetCategories(a.Key, a.categories)
+	registry.SetPublic(a.Key, a.PublicArchive)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+
+	if old != nil {
+		a.errors = old.errors
+		a.plugins = old.plugins
+		a.pluginNames = old.pluginNames
+		a.notLoaded = old.notLoaded
+		a.pluginCancel = old.pluginCancel
+	} else {
+		a.errors = make(chan string, 100)
+		pluginCtx, pluginCancel := context.WithCancel(context.Background())
+		a.pluginCancel = pluginCancel
+
+		for i := range a.Plugins {
+			pf, ok := registry.GetPlugin(a.Plugins[i])
+			if !ok {
+				return fmt.Errorf("announcement: unknown plugin %s", a.Plugins[i])
+			}
+			p, err := pf(a.Key, a.ShortDescription, a.errors)
+			if err != nil {
+				log.Printf("announcement.Initialise: plugin %s has error %s", a.Plugins[i], err.Error())
+				a.notLoaded[a.Plugins[i]] = err.Error()
+				continue
+			}
+			err = p.Start(pluginCtx)
+			if err != nil {
+				log.Printf("announcement.Initialise: plugin %s can not start: %s", a.Plugins[i], err.Error())
+				a.notLoaded[a.Plugins[i]] = err.Error()
+				continue
+			}
+			a.plugins = append(a.plugins, p)
+			a.pluginNames = append(a.pluginNames, a.Plugins[i])
 		}
-		a.plugins = append(a.plugins, p)
 	}
 
-	err := server.AddHandle(a.Key, "", func(rw http.ResponseWriter, r *http.Request) {
+	err := a.addHandle("", func(rw http.ResponseWriter, r *http.Request) {
 		rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
 
 		// Test login
@@ -158,6 +320,7 @@ func (a *announcement) Initialise() error {
 				Key:              a.Key,
 				ShortDescription: a.ShortDescription,
 				Translation:      translation.GetDefaultTranslation(),
+				PublicArchive:    a.PublicArchive,
 			}
 			err := templates.LoginTemplate.Execute(rw, td)
 			if err != nil {
@@ -177,6 +340,34 @@ func (a *announcement) Initialise() error {
 				Translation:      translation.GetDefaultTranslation(),
 				Messages:         a.messages,
 				NotLoaded:        make([]string, 0, len(a.notLoaded)),
+				Drafts:           append([]templates.Draft(nil), a.drafts...),
+				Submissions:      a.visibleSubmissions(admin, server.GetIdentity(a.Key, r)),
+				ReviewRequired:   a.UsersRequireApproval && !admin,
+				Plugins:          a.pluginNames,
+				Attachments:      a.AttachmentMaxSize > 0,
+				AttachmentTypes:  strings.Join(a.AttachmentExtensions, ","),
+				Categories:       append([]string{}, a.categories...),
+				Languages:        variantLanguages(),
+				Templates:        append([]templates.MessageTemplate(nil), a.messageTemplates...),
+			}
+			draft := r.URL.Query().Get("draft")
+			for i := range a.drafts {
+				if a.drafts[i].ID == draft {
+					td.Draft = a.drafts[i]
+					break
+				}
+			}
+			messageTemplate := r.URL.Query().Get("template")
+			for i := range a.messageTemplates {
+				if a.messageTemplates[i].ID == messageTemplate {
+					td.Template = a.messageTemplates[i]
+					td.TemplateFields = td.Template.Fields()
+					break
+				}
+			}
+			if admin {
+				td.Scheduled = append(td.Scheduled, a.scheduled...)
+				td.Recurring = append(td.Recurring, a.recurring...)
 			}
 			if admin || a.UsersSeeErrors {
 				td.ShowErrors = true
@@ -192,73 +383,723 @@ func (a *announcement) Initialise() error {
 				for i := range a.plugins {
 					td.PluginConfig = append(td.PluginConfig, a.plugins[i].GetConfig())
 				}
-			}
-
-			err := r.ParseForm()
-			if err != nil {
-				rw.WriteHeader(http.StatusInternalServerError)
-				t := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
-				templates.TextTemplate.Execute(rw, t)
-			}
+			}
+
+			if td.Template.ID != "" && r.URL.Query().Get("apply") != "" {
+				values := make(map[string]string, len(td.TemplateFields))
+				for _, f := range td.TemplateFields {
+					values[f] = r.URL.Query().Get(strings.Join([]string{"field", f}, "_"))
+				}
+				header, message, err := td.Template.Execute(values)
+				if err != nil {
+					rw.WriteHeader(http.StatusBadRequest)
+					t := templates.TextTemplateStruct{Text: template.HTML(template.HTMLEscapeString(fmt.Sprintf("400 Bad Request: %s", err.Error()))), Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, t)
+					return
+				}
+				td.Draft = templates.Draft{Header: header, Message: message}
+			}
+
+			err := r.ParseForm()
+			if err != nil {
+				rw.WriteHeader(http.StatusInternalServerError)
+				t := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
+				templates.TextTemplate.Execute(rw, t)
+			}
+
+			err = templates.AnnouncementTemplate.Execute(rw, td)
+			if err != nil {
+				log.Println("announcement get:", err.Error())
+			}
+			return
+
+		case http.MethodPost:
+			r.Body = http.MaxBytesReader(rw, r.Body, int64(a.AttachmentMaxSize)*attachmentMaxNumber+10<<20)
+			err := r.ParseMultipartForm(1 << 20)
+			if err != nil && err != http.ErrNotMultipart {
+				rw.WriteHeader(http.StatusBadRequest)
+				t := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+				templates.TextTemplate.Execute(rw, t)
+				return
+			}
+			if r.MultipartForm != nil {
+				defer r.MultipartForm.RemoveAll()
+			}
+
+			switch r.Form.Get("target") {
+			case "publish":
+				if r.Form.Get("dsgvo") == "" {
+					rw.WriteHeader(http.StatusPreconditionFailed)
+					td := templates.TextTemplateStruct{Text: "412 Precondition Failed", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+
+				subject := r.Form.Get("subject")
+				message := r.Form.Get("message")
+				if message == "" || subject == "" {
+					rw.WriteHeader(http.StatusBadRequest)
+					td := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+				an := registry.Announcement{
+					Header:  subject,
+					Message: message,
+					Time:    time.Now(),
+					Author:  server.GetIdentity(a.Key, r),
+					Plugins: a.selectedPlugins(r.Form["plugin"]),
+				}
+				if categories := registry.FilterCategories(a.Key, r.Form["category"]); len(categories) != 0 {
+					an.Categories = categories
+				}
+				an.Variants = readVariants(r)
+				an.Priority, err = registry.ParsePriority(r.Form.Get("priority"))
+				if err != nil {
+					rw.WriteHeader(http.StatusBadRequest)
+					td := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+				if len(an.Plugins) == 0 {
+					rw.WriteHeader(http.StatusBadRequest)
+					td := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+
+				var publishTime time.Time
+				if r.Form.Get("publishtime") != "" {
+					publishTime, err = time.ParseInLocation(scheduleTimeFormat, r.Form.Get("publishtime"), time.Local)
+					if err != nil {
+						rw.WriteHeader(http.StatusBadRequest)
+						td := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+						templates.TextTemplate.Execute(rw, td)
+						return
+					}
+				}
+
+				if r.Form.Get("expires") != "" {
+					an.Expires, err = time.ParseInLocation(scheduleTimeFormat, r.Form.Get("expires"), time.Local)
+					if err != nil || !an.Expires.After(an.Time) || !an.Expires.After(publishTime) {
+						rw.WriteHeader(http.StatusBadRequest)
+						td := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+						templates.TextTemplate.Execute(rw, td)
+						return
+					}
+				}
+
+				uploads, err := a.readAttachments(r)
+				if err != nil {
+					rw.WriteHeader(http.StatusBadRequest)
+					td := templates.TextTemplateStruct{Text: template.HTML(template.HTMLEscapeString(fmt.Sprintf("400 Bad Request: %s", err.Error()))), Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+				an.Attachments, err = a.saveAttachments(uploads)
+				if err != nil {
+					log.Printf("announcement attachment (%s): %s", a.Key, err.Error())
+					rw.WriteHeader(http.StatusInternalServerError)
+					td := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+
+				if a.UsersRequireApproval && !admin {
+					a.l.Lock()
+					err = a.submit(an, publishTime)
+					if err == nil && r.Form.Get("draft") != "" {
+						a.removeDraft(r.Form.Get("draft"))
+					}
+					a.l.Unlock()
+					if err != nil {
+						log.Printf("announcement submit (%s): %s", a.Key, err.Error())
+						rw.WriteHeader(http.StatusInternalServerError)
+						td := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
+						templates.TextTemplate.Execute(rw, td)
+						return
+					}
+					a.audit(r, "submit", an.Header)
+					http.Redirect(rw, r, fmt.Sprintf("/%s", a.Key), http.StatusSeeOther)
+					return
+				}
+
+				if publishTime.After(an.Time) {
+					a.l.Lock()
+					err = a.schedule(an, publishTime)
+					if err == nil && r.Form.Get("draft") != "" {
+						a.removeDraft(r.Form.Get("draft"))
+					}
+					a.l.Unlock()
+					if err != nil {
+						log.Printf("announcement schedule (%s): %s", a.Key, err.Error())
+						rw.WriteHeader(http.StatusInternalServerError)
+						td := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
+						templates.TextTemplate.Execute(rw, td)
+						return
+					}
+					a.audit(r, "schedule", fmt.Sprintf("%s (%s)", an.Header, publishTime.Format(time.RFC3339)))
+					http.Redirect(rw, r, fmt.Sprintf("/%s", a.Key), http.StatusSeeOther)
+					return
+				}
+
+				id := a.publish(an)
+				if id != "" && r.Form.Get("draft") != "" {
+					a.l.Lock()
+					a.removeDraft(r.Form.Get("draft"))
+					a.l.Unlock()
+				}
+				a.audit(r, "publish", fmt.Sprintf("%s: %s", id, an.Header))
+				http.Redirect(rw, r, fmt.Sprintf("/%s", a.Key), http.StatusSeeOther)
+				return
+			case "draft":
+				subject := r.Form.Get("subject")
+				message := r.Form.Get("message")
+				if message == "" && subject == "" {
+					rw.WriteHeader(http.StatusBadRequest)
+					td := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+
+				d := templates.Draft{
+					ID:      r.Form.Get("draft"),
+					Header:  subject,
+					Message: message,
+					Time:    time.Now(),
+				}
+
+				newID, err := helper.NewID()
+				if err != nil {
+					log.Printf("announcement draft (%s): %s", a.Key, err.Error())
+					rw.WriteHeader(http.StatusInternalServerError)
+					td := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+
+				a.l.Lock()
+				found := false
+				for i := range a.drafts {
+					if d.ID != "" && a.drafts[i].ID == d.ID {
+						a.drafts[i] = d
+						found = true
+						break
+					}
+				}
+				if !found {
+					d.ID = newID
+					a.drafts = append(a.drafts, d)
+				}
+				a.saveInternal("drafts", &a.drafts)
+				a.l.Unlock()
+				http.Redirect(rw, r, fmt.Sprintf("/%s?draft=%s", a.Key, url.QueryEscape(d.ID)), http.StatusSeeOther)
+				return
+			case "draftdelete":
+				a.l.Lock()
+				a.removeDraft(r.Form.Get("draft"))
+				a.l.Unlock()
+				http.Redirect(rw, r, fmt.Sprintf("/%s", a.Key), http.StatusSeeOther)
+				return
+			case "reviewapprove", "reviewreject":
+				if !admin {
+					rw.WriteHeader(http.StatusForbidden)
+					td := templates.TextTemplateStruct{Text: "403 Forbidden", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+
+				subject := r.Form.Get("subject")
+				message := r.Form.Get("message")
+				if r.Form.Get("target") == "reviewapprove" {
+					if subject == "" || message == "" {
+						rw.WriteHeader(http.StatusBadRequest)
+						td := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+						templates.TextTemplate.Execute(rw, td)
+						return
+					}
+				}
+
+				a.l.Lock()
+				var an registry.Announcement
+				var publish bool
+				switch r.Form.Get("target") {
+				case "reviewapprove":
+					an, publish, err = a.approve(r.Form.Get("id"), subject, message)
+				case "reviewreject":
+					err = a.reject(r.Form.Get("id"), r.Form.Get("reason"))
+				}
+				a.l.Unlock()
+				if errors.Is(err, errUnknownSubmission) {
+					rw.WriteHeader(http.StatusNotFound)
+					td := templates.TextTemplateStruct{Text: "404 Not Found", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+				if err != nil {
+					log.Printf("announcement review (%s): %s", a.Key, err.Error())
+					rw.WriteHeader(http.StatusInternalServerError)
+					td := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+				a.audit(r, r.Form.Get("target"), r.Form.Get("id"))
+				if publish {
+					id := a.publish(an)
+					a.audit(r, "publish", fmt.Sprintf("%s: %s", id, an.Header))
+				}
+				http.Redirect(rw, r, fmt.Sprintf("/%s", a.Key), http.StatusSeeOther)
+				return
+			case "scheduleedit", "schedulecancel":
+				if !admin {
+					rw.WriteHeader(http.StatusForbidden)
+					td := templates.TextTemplateStruct{Text: "403 Forbidden", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+
+				var publishTime time.Time
+				subject := r.Form.Get("subject")
+				message := r.Form.Get("message")
+				if r.Form.Get("target") == "scheduleedit" {
+					publishTime, err = time.ParseInLocation(scheduleTimeFormat, r.Form.Get("publishtime"), time.Local)
+					if err != nil || message == "" || subject == "" || !publishTime.After(time.Now()) {
+						rw.WriteHeader(http.StatusBadRequest)
+						td := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+						templates.TextTemplate.Execute(rw, td)
+						return
+					}
+				}
+
+				id := r.Form.Get("id")
+				a.l.Lock()
+				found := false
+				valid := true
+				for i := range a.scheduled {
+					if a.scheduled[i].ID != id {
+						continue
+					}
+					found = true
+					if r.Form.Get("target") == "schedulecancel" {
+						a.scheduled = append(a.scheduled[:i], a.scheduled[i+1:]...)
+					} else {
+						if expires := a.scheduled[i].Announcement.Expires; !expires.IsZero() && !expires.After(publishTime) {
+							// The announcement would expire before it is published
+							valid = false
+							break
+						}
+						a.scheduled[i].Announcement.Header = subject
+						a.scheduled[i].Announcement.Message = message
+						a.scheduled[i].PublishTime = publishTime
+					}
+					counter.StartProcess()
+					a.saveInternal("scheduled", &a.scheduled)
+					counter.EndProcess()
+					break
+				}
+				a.l.Unlock()
+
+				if !valid {
+					rw.WriteHeader(http.StatusBadRequest)
+					td := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+				if !found {
+					rw.WriteHeader(http.StatusNotFound)
+					td := templates.TextTemplateStruct{Text: "404 Not Found", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+				a.audit(r, r.Form.Get("target"), id)
+				http.Redirect(rw, r, fmt.Sprintf("/%s", a.Key), http.StatusSeeOther)
+				return
+			case "categoryadd", "categorydelete":
+				if !admin {
+					rw.WriteHeader(http.StatusForbidden)
+					td := templates.TextTemplateStruct{Text: "403 Forbidden", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+
+				name := strings.TrimSpace(r.Form.Get("category"))
+				if name == "" {
+					rw.WriteHeader(http.StatusBadRequest)
+					td := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+
+				a.l.Lock()
+				counter.StartProcess()
+				categories := make([]string, 0, len(a.categories)+1)
+				for i := range a.categories {
+					if a.categories[i] != name {
+						categories = append(categories, a.categories[i])
+					}
+				}
+				if r.Form.Get("target") == "categoryadd" {
+					categories = append(categories, name)
+				}
+				a.categories = categories
+				a.saveInternal("categories", &a.categories)
+				registry.SetCategories(a.Key, a.categories)
+				counter.EndProcess()
+				a.l.Unlock()
+				a.audit(r, r.Form.Get("target"), name)
+				http.Redirect(rw, r, fmt.Sprintf("/%s", a.Key), http.StatusSeeOther)
+				return
+			case "templateadd", "templateedit", "templatedelete":
+				if !admin {
+					rw.WriteHeader(http.StatusForbidden)
+					td := templates.TextTemplateStruct{Text: "403 Forbidden", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+
+				mt := templates.MessageTemplate{
+					ID:      r.Form.Get("id"),
+					Name:    r.Form.Get("name"),
+					Header:  r.Form.Get("subject"),
+					Message: r.Form.Get("message"),
+				}
+				if r.Form.Get("target") != "templatedelete" {
+					if mt.Name == "" || mt.Header == "" || mt.Message == "" {
+						rw.WriteHeader(http.StatusBadRequest)
+						td := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+						templates.TextTemplate.Execute(rw, td)
+						return
+					}
+					err = mt.Validate()
+					if err != nil {
+						rw.WriteHeader(http.StatusBadRequest)
+						td := templates.TextTemplateStruct{Text: template.HTML(template.HTMLEscapeString(fmt.Sprintf("400 Bad Request: %s", err.Error()))), Translation: translation.GetDefaultTranslation()}
+						templates.TextTemplate.Execute(rw, td)
+						return
+					}
+				}
+
+				if r.Form.Get("target") == "templateadd" {
+					mt.ID, err = helper.NewID()
+					if err != nil {
+						log.Printf("announcement template (%s): %s", a.Key, err.Error())
+						rw.WriteHeader(http.StatusInternalServerError)
+						td := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
+						templates.TextTemplate.Execute(rw, td)
+						return
+					}
+				}
+
+				a.l.Lock()
+				found := false
+				switch r.Form.Get("target") {
+				case "templateadd":
+					a.messageTemplates = append(a.messageTemplates, mt)
+					found = true
+				default:
+					for i := range a.messageTemplates {
+						if a.messageTemplates[i].ID != mt.ID {
+							continue
+						}
+						found = true
+						if r.Form.Get("target") == "templatedelete" {
+							a.messageTemplates = append(a.messageTemplates[:i], a.messageTemplates[i+1:]...)
+						} else {
+							a.messageTemplates[i] = mt
+						}
+						break
+					}
+				}
+				if found {
+					a.saveInternal("templates", &a.messageTemplates)
+				}
+				a.l.Unlock()
+
+				if !found {
+					rw.WriteHeader(http.StatusNotFound)
+					td := templates.TextTemplateStruct{Text: "404 Not Found", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+				a.audit(r, r.Form.Get("target"), fmt.Sprintf("%s: %s", mt.ID, mt.Name))
+				http.Redirect(rw, r, fmt.Sprintf("/%s", a.Key), http.StatusSeeOther)
+				return
+			case "recurringadd":
+				if !admin {
+					rw.WriteHeader(http.StatusForbidden)
+					td := templates.TextTemplateStruct{Text: "403 Forbidden", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+
+				ra := templates.RecurringAnnouncement{
+					Header:   r.Form.Get("subject"),
+					Message:  r.Form.Get("message"),
+					Schedule: r.Form.Get("schedule"),
+					Location: r.Form.Get("location"),
+					Author:   server.GetIdentity(a.Key, r),
+				}
+				if ra.Header == "" || ra.Message == "" {
+					rw.WriteHeader(http.StatusBadRequest)
+					td := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+				ra.ID, err = helper.NewID()
+				if err != nil {
+					log.Printf("announcement recurring (%s): %s", a.Key, err.Error())
+					rw.WriteHeader(http.StatusInternalServerError)
+					td := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+				ra.NextRun, err = nextRecurringRun(ra, time.Now())
+				if err != nil {
+					rw.WriteHeader(http.StatusBadRequest)
+					td := templates.TextTemplateStruct{Text: template.HTML(template.HTMLEscapeString(fmt.Sprintf("400 Bad Request: %s", err.Error()))), Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+
+				a.l.Lock()
+				counter.StartProcess()
+				a.recurring = append(a.recurring, ra)
+				a.saveInternal("recurring", &a.recurring)
+				counter.EndProcess()
+				a.l.Unlock()
+				a.audit(r, "recurringadd", fmt.Sprintf("%s: %s (%s)", ra.ID, ra.Header, ra.Schedule))
+				http.Redirect(rw, r, fmt.Sprintf("/%s", a.Key), http.StatusSeeOther)
+				return
+			case "recurringpause", "recurringskip", "recurringend":
+				if !admin {
+					rw.WriteHeader(http.StatusForbidden)
+					td := templates.TextTemplateStruct{Text: "403 Forbidden", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
 
-			err = templates.AnnouncementTemplate.Execute(rw, td)
-			if err != nil {
-				log.Println("announcement get:", err.Error())
-			}
-			return
+				id := r.Form.Get("id")
+				a.l.Lock()
+				found := false
+				for i := range a.recurring {
+					if a.recurring[i].ID != id {
+						continue
+					}
+					found = true
+					var next time.Time
+					var err error
+					switch r.Form.Get("target") {
+					case "recurringpause":
+						if a.recurring[i].Paused {
+							// Runs during the pause are not sent
+							next, err = nextRecurringRun(a.recurring[i], time.Now())
+							if err == nil {
+								a.recurring[i].NextRun = next
+								a.recurring[i].Paused = false
+							}
+						} else {
+							a.recurring[i].Paused = true
+						}
+					case "recurringskip":
+						next, err = nextRecurringRun(a.recurring[i], a.recurring[i].NextRun)
+						if err == nil {
+							a.recurring[i].NextRun = next
+						}
+					case "recurringend":
+						a.recurring = append(a.recurring[:i], a.recurring[i+1:]...)
+					}
+					counter.StartProcess()
+					if err != nil {
+						a.addMessage(err.Error(), true)
+					}
+					a.saveInternal("recurring", &a.recurring)
+					counter.EndProcess()
+					break
+				}
+				a.l.Unlock()
 
-		case http.MethodPost:
-			err := r.ParseForm()
-			if err != nil {
-				rw.WriteHeader(http.StatusInternalServerError)
-				t := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
-				templates.TextTemplate.Execute(rw, t)
+				if !found {
+					rw.WriteHeader(http.StatusNotFound)
+					td := templates.TextTemplateStruct{Text: "404 Not Found", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+				a.audit(r, r.Form.Get("target"), id)
+				http.Redirect(rw, r, fmt.Sprintf("/%s", a.Key), http.StatusSeeOther)
 				return
-			}
-
-			switch r.Form.Get("target") {
-			case "publish":
-				if r.Form.Get("dsgvo") == "" {
-					rw.WriteHeader(http.StatusPreconditionFailed)
-					td := templates.TextTemplateStruct{Text: "412 Precondition Failed", Translation: translation.GetDefaultTranslation()}
+			case "edit":
+				if !admin {
+					rw.WriteHeader(http.StatusForbidden)
+					td := templates.TextTemplateStruct{Text: "403 Forbidden", Translation: translation.GetDefaultTranslation()}
 					templates.TextTemplate.Execute(rw, td)
 					return
 				}
 
+				id := r.Form.Get("id")
 				subject := r.Form.Get("subject")
 				message := r.Form.Get("message")
-				if message == "" || subject == "" {
+				if id == "" || message == "" || subject == "" {
 					rw.WriteHeader(http.StatusBadRequest)
 					td := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
 					templates.TextTemplate.Execute(rw, td)
 					return
 				}
-				an := registry.Announcement{
-					Header:  subject,
-					Message: message,
-					Time:    time.Now(),
+
+				an, err := registry.CurrentDataSafe.GetAnnouncement(a.Key, id)
+				if err != nil {
+					log.Printf("announcement edit (%s): %s", a.Key, err.Error())
+					rw.WriteHeader(http.StatusNotFound)
+					td := templates.TextTemplateStruct{Text: "404 Not Found", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+				if !an.Retracted.IsZero() {
+					rw.WriteHeader(http.StatusBadRequest)
+					td := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+				an.Header = subject
+				an.Message = message
+				an.Variants = readVariants(r)
+				an.Edited = time.Now()
+				an.Expires = time.Time{}
+				if r.Form.Get("expires") != "" {
+					an.Expires, err = time.ParseInLocation(scheduleTimeFormat, r.Form.Get("expires"), time.Local)
+					if err != nil {
+						rw.WriteHeader(http.StatusBadRequest)
+						td := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+						templates.TextTemplate.Execute(rw, td)
+						return
+					}
+				}
+				err = registry.CurrentDataSafe.UpdateAnnouncement(a.Key, id, an)
+				if err != nil {
+					log.Printf("announcement edit (%s): %s", a.Key, err.Error())
+					rw.WriteHeader(http.StatusInternalServerError)
+					td := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
 				}
-				id, err := registry.CurrentDataSafe.SaveAnnouncement(a.Key, an)
+				plugins := a.targetPlugins(an)
+				for i := range plugins {
+					go plugins[i].UpdateAnnouncement(an, id)
+				}
+				a.l.Lock()
+				counter.StartProcess()
+				a.watchExpiry(id, an.Expires)
+				a.addMessage(translation.GetDefaultTranslation().AnnouncementUpdated, false)
+				counter.EndProcess()
+				a.l.Unlock()
+				a.audit(r, "edit", fmt.Sprintf("%s: %s", id, an.Header))
+				http.Redirect(rw, r, fmt.Sprintf("/%s/history.html", a.Key), http.StatusSeeOther)
+				return
+			case "retract":
+				if !admin {
+					rw.WriteHeader(http.StatusForbidden)
+					td := templates.TextTemplateStruct{Text: "403 Forbidden", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+
+				id := r.Form.Get("id")
+				an, err := registry.CurrentDataSafe.GetAnnouncement(a.Key, id)
+				if err != nil {
+					log.Printf("announcement retract (%s): %s", a.Key, err.Error())
+					rw.WriteHeader(http.StatusNotFound)
+					td := templates.TextTemplateStruct{Text: "404 Not Found", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+				if !an.Retracted.IsZero() {
+					// Already retracted
+					http.Redirect(rw, r, fmt.Sprintf("/%s/history.html", a.Key), http.StatusSeeOther)
+					return
+				}
+				an.Retracted = time.Now()
+				err = registry.CurrentDataSafe.RetractAnnouncement(a.Key, id, an.Retracted)
 				if err != nil {
-					log.Println("announcement save:", err.Error())
+					log.Printf("announcement retract (%s): %s", a.Key, err.Error())
+					rw.WriteHeader(http.StatusInternalServerError)
+					td := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+				plugins := a.targetPlugins(an)
+				for i := range plugins {
+					go plugins[i].RetractAnnouncement(an, id)
+				}
+				a.l.Lock()
+				counter.StartProcess()
+				a.watchExpiry(id, time.Time{})
+				a.addMessage(translation.GetDefaultTranslation().AnnouncementWasRetracted, false)
+				counter.EndProcess()
+				a.l.Unlock()
+				a.audit(r, "retract", fmt.Sprintf("%s: %s", id, an.Header))
+				http.Redirect(rw, r, fmt.Sprintf("/%s/history.html", a.Key), http.StatusSeeOther)
+				return
+			case "resend":
+				if !admin {
+					rw.WriteHeader(http.StatusForbidden)
+					td := templates.TextTemplateStruct{Text: "403 Forbidden", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+
+				id := r.Form.Get("id")
+				an, err := registry.CurrentDataSafe.GetAnnouncement(a.Key, id)
+				if err != nil {
+					log.Printf("announcement resend (%s): %s", a.Key, err.Error())
+					rw.WriteHeader(http.StatusNotFound)
+					td := templates.TextTemplateStruct{Text: "404 Not Found", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
+				}
+				an.ID = id
+
+				// Only plugins the announcement was sent to can be selected, so later edits and retractions still reach all recipients
+				var selected []string
+				for _, p := range a.selectedPlugins(r.Form["plugin"]) {
+					if an.SentTo(p) {
+						selected = append(selected, p)
+					}
+				}
+				if !an.Retracted.IsZero() || an.Expired() || len(selected) == 0 {
+					rw.WriteHeader(http.StatusBadRequest)
+					td := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+					templates.TextTemplate.Execute(rw, td)
+					return
 				}
 				for i := range a.plugins {
-					go a.plugins[i].NewAnnouncement(an, id)
+					for j := range selected {
+						if a.pluginNames[i] == selected[j] {
+							registry.ReportDeliveryQueued(a.Key, id, a.pluginNames[i], 0)
+							go a.plugins[i].NewAnnouncement(an, id)
+							break
+						}
+					}
 				}
 				a.l.Lock()
 				counter.StartProcess()
-				a.addMessage(translation.GetDefaultTranslation().AnnouncementPublished, false)
+				a.addMessage(translation.GetDefaultTranslation().AnnouncementResent, false)
 				counter.EndProcess()
 				a.l.Unlock()
-				http.Redirect(rw, r, fmt.Sprintf("/%s", a.Key), http.StatusSeeOther)
+				a.audit(r, "resend", fmt.Sprintf("%s: %s\n%s", id, an.Header, strings.Join(selected, ", ")))
+				http.Redirect(rw, r, fmt.Sprintf("/%s/history.html", a.Key), http.StatusSeeOther)
 				return
 			default:
 				t := r.Form.Get("target")
-				for i := range a.Plugins {
-					if t == a.Plugins[i] {
+				for i := range a.pluginNames {
+					if t == a.pluginNames[i] {
+						before := a.plugins[i].Settings()
 						err = a.plugins[i].ProcessConfigChange(r)
+						details := strings.Join([]string{a.pluginNames[i], settingsDiff(before, a.plugins[i].Settings())}, "\n")
 						if err != nil {
-							log.Printf("announcement plugin config (%s): %s", a.Plugins[i], err.Error())
+							details = fmt.Sprintf("%s\nerror: %s", details, err.Error())
+						}
+						a.audit(r, "config", strings.TrimSpace(details))
+						if err != nil {
+							log.Printf("announcement plugin config (%s): %s", a.pluginNames[i], err.Error())
 							a.l.Lock()
 							counter.StartProcess()
 							a.addMessage(err.Error(), true)
@@ -287,7 +1128,7 @@ func (a *announcement) Initialise() error {
 		return err
 	}
 
-	err = server.AddHandle(a.Key, "login", func(rw http.ResponseWriter, r *http.Request) {
+	err = a.addHandle("login", func(rw http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			rw.WriteHeader(http.StatusBadRequest)
 			t := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
@@ -311,7 +1152,7 @@ func (a *announcement) Initialise() error {
 		}
 
 		for i := range a.PasswordUser {
-			ok, err := registry.ComparePasswords(a.PasswordMethod, password, a.PasswordUser[i])
+			ok, err := registry.ComparePasswords(a.PasswordMethod, password, a.PasswordUser[i].Password)
 			if err != nil {
 				rw.WriteHeader(http.StatusInternalServerError)
 				t := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
@@ -320,20 +1161,21 @@ func (a *announcement) Initialise() error {
 			}
 
 			if ok {
-				err := server.SetLoginCookie(a.Key, false, rw, r)
+				err := server.SetLoginCookie(a.Key, false, a.PasswordUser[i].Name, rw, r)
 				if err != nil {
 					rw.WriteHeader(http.StatusInternalServerError)
 					t := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
 					templates.TextTemplate.Execute(rw, t)
 					return
 				}
+				a.auditAs(r, auditRoleUser, a.PasswordUser[i].Name, "login", "")
 				http.Redirect(rw, r, fmt.Sprintf("/%s", a.Key), http.StatusSeeOther)
 				return
 			}
 		}
 
 		for i := range a.PasswordAdmin {
-			ok, err := registry.ComparePasswords(a.PasswordMethod, password, a.PasswordAdmin[i])
+			ok, err := registry.ComparePasswords(a.PasswordMethod, password, a.PasswordAdmin[i].Password)
 			if err != nil {
 				rw.WriteHeader(http.StatusInternalServerError)
 				t := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
@@ -342,13 +1184,14 @@ func (a *announcement) Initialise() error {
 			}
 
 			if ok {
-				err := server.SetLoginCookie(a.Key, true, rw, r)
+				err := server.SetLoginCookie(a.Key, true, a.PasswordAdmin[i].Name, rw, r)
 				if err != nil {
 					rw.WriteHeader(http.StatusInternalServerError)
 					t := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
 					templates.TextTemplate.Execute(rw, t)
 					return
 				}
+				a.auditAs(r, auditRoleAdmin, a.PasswordAdmin[i].Name, "login", "")
 				http.Redirect(rw, r, fmt.Sprintf("/%s", a.Key), http.StatusSeeOther)
 				return
 			}
@@ -357,6 +1200,7 @@ func (a *announcement) Initialise() error {
 		if config.LogFailedLogin {
 			log.Printf("Failed login from %s", helper.GetRealIP(r))
 		}
+		a.auditLoginFailed(r)
 		rw.WriteHeader(http.StatusForbidden)
 		t := templates.TextTemplateStruct{Text: "403 Forbidden", Translation: translation.GetDefaultTranslation()}
 		templates.TextTemplate.Execute(rw, t)
@@ -365,7 +1209,10 @@ func (a *announcement) Initialise() error {
 		return err
 	}
 
-	err = server.AddHandle(a.Key, "logout", func(rw http.ResponseWriter, r *http.Request) {
+	err = a.addHandle("logout", func(rw http.ResponseWriter, r *http.Request) {
+		if loggedin, _ := server.GetLogin(a.Key, r); loggedin {
+			a.audit(r, "logout", "")
+		}
 		server.RemoveLoginCookie(a.Key, rw, r)
 		http.Redirect(rw, r, fmt.Sprintf("/%s", a.Key), http.StatusSeeOther)
 	})
@@ -373,7 +1220,12 @@ func (a *announcement) Initialise() error {
 		return err
 	}
 
-	err = server.AddHandle(a.Key, "history.html", func(rw http.ResponseWriter, r *http.Request) {
+	err = a.addHandle("history.html", a.historyHandle)
+	if err != nil {
+		return err
+	}
+
+	err = a.addHandle("revisions.html", func(rw http.ResponseWriter, r *http.Request) {
 		rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
 		loggedin, _ := server.GetLogin(a.Key, r)
 		if !loggedin {
@@ -383,15 +1235,25 @@ func (a *announcement) Initialise() error {
 			return
 		}
 
-		h, err := registry.CurrentDataSafe.GetAllAnnouncements(a.Key)
+		id := r.URL.Query().Get("id")
+		current, err := registry.CurrentDataSafe.GetAnnouncement(a.Key, id)
+		if err != nil {
+			rw.WriteHeader(http.StatusNotFound)
+			t := templates.TextTemplateStruct{Text: "404 Not Found", Translation: translation.GetDefaultTranslation()}
+			templates.TextTemplate.Execute(rw, t)
+			return
+		}
+
+		h, err := registry.CurrentDataSafe.GetAnnouncementRevisions(a.Key, id)
 		if err != nil {
-			log.Printf("announcement history (%s): %s", a.Key, err.Error())
+			log.Printf("announcement revisions (%s): %s", a.Key, err.Error())
 			rw.WriteHeader(http.StatusInternalServerError)
 			t := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
 			templates.TextTemplate.Execute(rw, t)
 			return
 		}
 
+		h = append(h, current)
 		for i := 0; i < len(h)/2; i++ {
 			h[i], h[len(h)-1-i] = h[len(h)-1-i], h[i]
 		}
@@ -401,17 +1263,18 @@ func (a *announcement) Initialise() error {
 			ShortDescription: a.ShortDescription,
 			History:          h,
 			Translation:      translation.GetDefaultTranslation(),
+			Revisions:        true,
 		}
 		err = templates.HistoryTemplate.Execute(rw, td)
 		if err != nil {
-			log.Printf("announcement history template (%s): %s", a.Key, err.Error())
+			log.Printf("announcement revisions template (%s): %s", a.Key, err.Error())
 		}
 	})
 	if err != nil {
 		return err
 	}
 
-	err = server.AddHandle(a.Key, "deleteErrors", func(rw http.ResponseWriter, r *http.Request) {
+	err = a.addHandle("deleteErrors", func(rw http.ResponseWriter, r *http.Request) {
 		rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
 		loggedin, admin := server.GetLogin(a.Key, r)
 		if !loggedin {
@@ -436,15 +1299,171 @@ func (a *announcement) Initialise() error {
 		return err
 	}
 
-	go announcemetWorker(a, errorChannel)
+	err = a.addHandle("attachment/", a.attachmentHandle)
+	if err != nil {
+		return err
+	}
+
+	err = a.addHandle("preview", a.previewHandle)
+	if err != nil {
+		return err
+	}
+
+	err = a.addHandle("audit.html", a.auditHandle)
+	if err != nil {
+		return err
+	}
+
+	err = a.addHandle("audit.json", a.auditExportHandle)
+	if err != nil {
+		return err
+	}
+
+	err = a.addHandle("a/", a.permalinkHandle)
+	if err != nil {
+		return err
+	}
+
+	err = a.addHandle("delivery.html", a.deliveryHandle)
+	if err != nil {
+		return err
+	}
+
+	err = a.addHandle("reload", a.reloadHandle)
+	if err != nil {
+		return err
+	}
+
+	if a.PublicArchive {
+		err = a.addHandle("archive", a.archiveHandle)
+		if err != nil {
+			return err
+		}
+		if a.Robots {
+			a.allowRobots(fmt.Sprintf("/%s/archive", a.Key))
+			a.allowRobots(fmt.Sprintf("/%s/a/", a.Key))
+		}
+	}
+
+	go announcemetWorker(ctx, a, a.errors)
+	go scheduleWorker(ctx, a)
 
 	log.Println("announcement: sucessfully loaded", a.Key)
 	return nil
 }
 
-func announcemetWorker(a *announcement, errorChannel chan string) {
+// StopAnnouncements stops all loaded announcements in parallel.
+// It returns all plugins which did not stop before ctx is done in the form "key/plugin".
+func StopAnnouncements(ctx context.Context) []string {
+	knownKeysLock.Lock()
+	defer knownKeysLock.Unlock()
+
+	var wg sync.WaitGroup
+	var m sync.Mutex
+	var notStopped []string
+	for _, a := range knownKeys {
+		wg.Add(1)
+		go func(a *announcement) {
+			defer wg.Done()
+			n := a.Stop(ctx)
+			m.Lock()
+			notStopped = append(notStopped, n...)
+			m.Unlock()
+		}(a)
+	}
+	wg.Wait()
+	sort.Strings(notStopped)
+	return notStopped
+}
+
+// Stop stops all plugins and background workers of the announcement.
+// It returns all plugins which did not stop before ctx is done in the form "key/plugin".
+func (a *announcement) Stop(ctx context.Context) []string {
+	var wg sync.WaitGroup
+	var m sync.Mutex
+	var notStopped []string
+	for i := range a.plugins {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := a.plugins[i].Stop(ctx)
+			if err != nil {
+				log.Printf("announcement stop (%s): plugin %s: %s", a.Key, a.pluginNames[i], err.Error())
+				m.Lock()
+				notStopped = append(notStopped, strings.Join([]string{a.Key, a.pluginNames[i]}, "/"))
+				m.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if a.pluginCancel != nil {
+		a.pluginCancel()
+	}
+	// Workers are stopped last so errors of the plugins are still recorded
+	if a.cancel != nil {
+		a.cancel()
+	}
+	return notStopped
+}
+
+// addHandle adds a handler for the announcement to the server.
+// The handle is removed again when the announcement is detached.
+func (a *announcement) addHandle(handle string, h http.HandlerFunc) error {
+	err := server.AddHandle(a.Key, handle, h)
+	if err != nil {
+		return err
+	}
+	a.handles = append(a.handles, handle)
+	return nil
+}
+
+// allowRobots allows robots to access the path until the announcement is detached.
+func (a *announcement) allowRobots(path string) {
+	server.AllowRobots(path)
+	a.robots = append(a.robots, path)
+}
+
+// detach removes all handlers of the announcement and stops its background workers.
+// The plugins keep running, so they can be taken over by a new announcement with the same key.
+func (a *announcement) detach() {
+	for i := range a.handles {
+		server.RemoveHandle(a.Key, a.handles[i])
+	}
+	for i := range a.robots {
+		server.DisallowRobots(a.robots[i])
+	}
+	if a.cancel != nil {
+		a.cancel()
+	}
+
+	// Wait for running requests and workers changing the state
+	if a.l != nil {
+		a.l.Lock()
+		a.l.Unlock()
+	}
+}
+
+// teardown detaches the announcement and stops all plugins.
+// In contrast to detach, all handlers of the key are removed, including those of the plugins.
+func (a *announcement) teardown(ctx context.Context) {
+	a.detach()
+	server.RemoveHandles(a.Key)
+	notStopped := a.Stop(ctx)
+	for i := range notStopped {
+		log.Printf("announcement: plugin %s did not stop in time", notStopped[i])
+	}
+	registry.SetPublic(a.Key, false)
+}
+
+func announcemetWorker(ctx context.Context, a *announcement, errorChannel chan string) {
 	for {
-		e := <-errorChannel
+		var e string
+		select {
+		case <-ctx.Done():
+			return
+		case e = <-errorChannel:
+		}
 		counter.StartProcess()
 		a.l.Lock()
 		a.addMessage(e, true)
@@ -453,6 +1472,109 @@ func announcemetWorker(a *announcement, errorChannel chan string) {
 	}
 }
 
+// publish saves the announcement and sends it to all selected plugins.
+// If no plugins are selected, the announcement is sent to all plugins.
+// The caller must not hold a.l.
+func (a *announcement) publish(an registry.Announcement) string {
+	if an.Plugins == nil {
+		an.Plugins = append([]string{}, a.pluginNames...)
+	}
+	an = a.sign(an)
+	id, err := registry.CurrentDataSafe.SaveAnnouncement(a.Key, an)
+	if err != nil {
+		log.Println("announcement save:", err.Error())
+	}
+	an.ID = id
+	for i := range a.plugins {
+		if !an.SentTo(a.pluginNames[i]) {
+			continue
+		}
+		registry.ReportDeliveryQueued(a.Key, id, a.pluginNames[i], 0)
+		go a.plugins[i].NewAnnouncement(an, id)
+	}
+	a.l.Lock()
+	counter.StartProcess()
+	if !an.Expires.IsZero() && id != "" {
+		a.watchExpiry(id, an.Expires)
+	}
+	a.addMessage(translation.GetDefaultTranslation().AnnouncementPublished, false)
+	counter.EndProcess()
+	a.l.Unlock()
+	return id
+}
+
+// sign adds a signature line containing the author to all messages of the announcement if signatures are enabled.
+func (a *announcement) sign(an registry.Announcement) registry.Announcement {
+	if !a.AuthorSignature || an.Author == "" {
+		return an
+	}
+	an.Message = fmt.Sprintf("%s\n\n— %s", an.Message, an.Author)
+	variants := make([]registry.Variant, len(an.Variants))
+	for i := range an.Variants {
+		variants[i] = an.Variants[i]
+		variants[i].Message = fmt.Sprintf("%s\n\n— %s", variants[i].Message, an.Author)
+	}
+	if an.Variants != nil {
+		an.Variants = variants
+	}
+	return an
+}
+
+// selectedPlugins returns the names of all loaded plugins contained in selection, in the order of the configuration.
+func (a *announcement) selectedPlugins(selection []string) []string {
+	selected := make([]string, 0, len(selection))
+	for i := range a.pluginNames {
+		for j := range selection {
+			if a.pluginNames[i] == selection[j] {
+				selected = append(selected, a.pluginNames[i])
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// targetPlugins returns all loaded plugins the announcement was sent to.
+func (a *announcement) targetPlugins(an registry.Announcement) []registry.Plugin {
+	plugins := make([]registry.Plugin, 0, len(a.plugins))
+	for i := range a.plugins {
+		if an.SentTo(a.pluginNames[i]) {
+			plugins = append(plugins, a.plugins[i])
+		}
+	}
+	return plugins
+}
+
+// variantLanguages returns all languages an announcement can have a variant in.
+func variantLanguages() []string {
+	languages := translation.GetLanguages()
+	variants := make([]string, 0, len(languages))
+	for i := range languages {
+		if languages[i] != translation.GetDefaultTranslation().Language {
+			variants = append(variants, languages[i])
+		}
+	}
+	return variants
+}
+
+// readVariants returns all variants contained in the form.
+// Variants without subject or message are ignored.
+func readVariants(r *http.Request) []registry.Variant {
+	languages := variantLanguages()
+	var variants []registry.Variant
+	for i := range languages {
+		v := registry.Variant{
+			Language: languages[i],
+			Header:   r.Form.Get(strings.Join([]string{"subject", languages[i]}, "_")),
+			Message:  r.Form.Get(strings.Join([]string{"message", languages[i]}, "_")),
+		}
+		if v.Header != "" && v.Message != "" {
+			variants = append(variants, v)
+		}
+	}
+	return variants
+}
+
 func (a *announcement) loadErrors() {
 	// Caller needs to lock
 	counter.StartProcess()
@@ -499,6 +1621,59 @@ func (a *announcement) saveMessages() {
 	}
 }
 
+func (a *announcement) removeDraft(id string) {
+	// Caller needs to lock
+	for i := range a.drafts {
+		if a.drafts[i].ID == id {
+			a.drafts = append(a.drafts[:i], a.drafts[i+1:]...)
+			a.saveInternal("drafts", &a.drafts)
+			return
+		}
+	}
+}
+
+// loadInternal decodes the internal configuration with the given name into v.
+// v is not changed if no configuration is saved.
+// Caller needs to lock.
+func (a *announcement) loadInternal(name string, v any) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	b, err := registry.CurrentDataSafe.GetConfig(a.Key, strings.Join([]string{"internal", name}, "##"))
+	if err != nil {
+		log.Printf("loading %s (%s): %s", name, a.Key, err.Error())
+		return
+	}
+	if len(b) == 0 {
+		return
+	}
+	buf := bytes.NewBuffer(b)
+	dec := gob.NewDecoder(buf)
+	err = dec.Decode(v)
+	if err != nil {
+		log.Printf("decoding %s (%s): %s", name, a.Key, err.Error())
+		return
+	}
+}
+
+// saveInternal encodes v and saves it as the internal configuration with the given name.
+// Caller needs to lock.
+func (a *announcement) saveInternal(name string, v any) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	var config bytes.Buffer
+	enc := gob.NewEncoder(&config)
+	err := enc.Encode(v)
+	if err != nil {
+		log.Printf("encoding %s (%s): %s", name, a.Key, err.Error())
+		return
+	}
+	err = registry.CurrentDataSafe.SetConfig(a.Key, strings.Join([]string{"internal", name}, "##"), config.Bytes())
+	if err != nil {
+		log.Printf("saving %s (%s): %s", name, a.Key, err.Error())
+		return
+	}
+}
+
 func (a *announcement) addMessage(text string, error bool) {
 	a.messages = append(a.messages, templates.AnnouncementMessage{Text: fmt.Sprintf("%s: %s", time.Now().Format(time.RFC3339), text), Error: error})
 	a.saveMessages()
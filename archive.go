@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Top-Ranger/announcementgo/registry"
+	"github.com/Top-Ranger/announcementgo/server"
+	"github.com/Top-Ranger/announcementgo/templates"
+	"github.com/Top-Ranger/announcementgo/translation"
+)
+
+// archivePageSize is the number of announcements shown on a single page of the public archive.
+const archivePageSize = 10
+
+// archiveHandle shows a single page of the public archive.
+// The archive is accessible without login and only contains announcements which were not retracted.
+// Expired announcements are only shown to logged in users.
+func (a *announcement) archiveHandle(rw http.ResponseWriter, r *http.Request) {
+	loggedin, _ := server.GetLogin(a.Key, r)
+	page := 1
+	var err error
+	if r.URL.Query().Get("page") != "" {
+		page, err = strconv.Atoi(r.URL.Query().Get("page"))
+		if err != nil || page < 1 {
+			rw.WriteHeader(http.StatusBadRequest)
+			t := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+			templates.TextTemplate.Execute(rw, t)
+			return
+		}
+	}
+
+	q := registry.AnnouncementQuery{
+		HideRetracted: true,
+		HideExpired:   !loggedin,
+		Offset:        (page - 1) * archivePageSize,
+		Limit:         archivePageSize,
+	}
+	an, total, err := registry.CurrentDataSafe.SearchAnnouncements(a.Key, q)
+	if err != nil {
+		log.Printf("announcement archive (%s): %s", a.Key, err.Error())
+		rw.WriteHeader(http.StatusInternalServerError)
+		t := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+
+	td := templates.ArchiveTemplateStruct{
+		Key:              a.Key,
+		ShortDescription: a.ShortDescription,
+		Translation:      translation.GetDefaultTranslation(),
+		Announcements:    an,
+		Robots:           a.Robots,
+		Page:             page,
+		Pages:            (total + archivePageSize - 1) / archivePageSize,
+	}
+	if page > 1 {
+		td.PreviousPage = fmt.Sprintf("/%s/archive?page=%d", a.Key, page-1)
+	}
+	if page < td.Pages {
+		td.NextPage = fmt.Sprintf("/%s/archive?page=%d", a.Key, page+1)
+	}
+
+	// The same URL serves additional content to logged in users
+	rw.Header().Set("Vary", "Cookie")
+	if loggedin {
+		rw.Header().Set("Cache-Control", "private, no-cache")
+	} else {
+		rw.Header().Set("Cache-Control", "public, max-age=300")
+	}
+	err = templates.ArchiveTemplate.Execute(rw, td)
+	if err != nil {
+		log.Printf("announcement archive template (%s): %s", a.Key, err.Error())
+	}
+}
@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/Top-Ranger/announcementgo/registry"
+	"github.com/Top-Ranger/announcementgo/server"
+)
+
+// attachmentMaxNumber is the maximum number of files attached to a single announcement.
+const attachmentMaxNumber = 10
+
+// attachmentUpload holds an uploaded file which is not saved yet.
+type attachmentUpload struct {
+	attachment registry.Attachment
+	data       []byte
+}
+
+// readAttachments reads and validates all files uploaded in the form field "attachment".
+// The request must be parsed with ParseMultipartForm before.
+func (a *announcement) readAttachments(r *http.Request) ([]attachmentUpload, error) {
+	if r.MultipartForm == nil || len(r.MultipartForm.File["attachment"]) == 0 {
+		return nil, nil
+	}
+	files := r.MultipartForm.File["attachment"]
+	if a.AttachmentMaxSize <= 0 {
+		return nil, fmt.Errorf("attachments are not allowed")
+	}
+	if len(files) > attachmentMaxNumber {
+		return nil, fmt.Errorf("too many attachments (maximum: %d)", attachmentMaxNumber)
+	}
+
+	uploads := make([]attachmentUpload, 0, len(files))
+	for i := range files {
+		name := filepath.Base(files[i].Filename)
+		if name == "." || name == string(filepath.Separator) {
+			return nil, fmt.Errorf("invalid file name '%s'", files[i].Filename)
+		}
+		if files[i].Size > int64(a.AttachmentMaxSize) {
+			return nil, fmt.Errorf("%s is too large (maximum: %d bytes)", name, a.AttachmentMaxSize)
+		}
+		if !a.attachmentExtensionAllowed(name) {
+			return nil, fmt.Errorf("%s has a file type which is not allowed (allowed: %s)", name, strings.Join(a.AttachmentExtensions, ", "))
+		}
+
+		f, err := files[i].Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(io.LimitReader(f, int64(a.AttachmentMaxSize)+1))
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > a.AttachmentMaxSize {
+			return nil, fmt.Errorf("%s is too large (maximum: %d bytes)", name, a.AttachmentMaxSize)
+		}
+
+		contentType := mime.TypeByExtension(filepath.Ext(name))
+		if contentType == "" {
+			contentType = http.DetectContentType(data)
+		}
+		uploads = append(uploads, attachmentUpload{
+			attachment: registry.Attachment{Name: name, ContentType: contentType, Size: len(data)},
+			data:       data,
+		})
+	}
+	return uploads, nil
+}
+
+// attachmentExtensionAllowed returns whether a file with the given name may be attached.
+// All files are allowed if AttachmentExtensions is empty.
+func (a *announcement) attachmentExtensionAllowed(name string) bool {
+	if len(a.AttachmentExtensions) == 0 {
+		return true
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	for i := range a.AttachmentExtensions {
+		if strings.ToLower(a.AttachmentExtensions[i]) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// saveAttachments saves all uploads in the data safe and returns the resulting attachments.
+func (a *announcement) saveAttachments(uploads []attachmentUpload) ([]registry.Attachment, error) {
+	if len(uploads) == 0 {
+		return nil, nil
+	}
+	attachments := make([]registry.Attachment, 0, len(uploads))
+	for i := range uploads {
+		id, err := registry.CurrentDataSafe.SaveAttachment(a.Key, uploads[i].data)
+		if err != nil {
+			return nil, err
+		}
+		uploads[i].attachment.ID = id
+		attachments = append(attachments, uploads[i].attachment)
+	}
+	return attachments, nil
+}
+
+// attachmentHandle serves attachments under /<key>/attachment/<id>/<name>.
+// Attachments of keys with a public archive are accessible without login, since they are linked in public feeds.
+func (a *announcement) attachmentHandle(rw http.ResponseWriter, r *http.Request) {
+	loggedin, _ := server.GetLogin(a.Key, r)
+	if !loggedin && !a.PublicArchive {
+		rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, fmt.Sprintf("/%s/attachment/", a.Key))
+	split := strings.SplitN(path, "/", 2)
+	if len(split) != 2 || split[0] == "" {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	data, err := registry.CurrentDataSafe.GetAttachment(a.Key, split[0])
+	if err != nil {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(split[1]))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	rw.Header().Set("Content-Type", contentType)
+	rw.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": split[1]}))
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	if a.PublicArchive {
+		rw.Header().Set("Cache-Control", "public, max-age=43200")
+	} else {
+		rw.Header().Set("Cache-Control", "private, max-age=43200")
+	}
+	rw.Write(data)
+}
@@ -0,0 +1,275 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Top-Ranger/announcementgo/helper"
+	"github.com/Top-Ranger/announcementgo/registry"
+	"github.com/Top-Ranger/announcementgo/server"
+	"github.com/Top-Ranger/announcementgo/templates"
+	"github.com/Top-Ranger/announcementgo/translation"
+)
+
+// All roles recorded in the audit log.
+const (
+	auditRoleAdmin = "admin"
+	auditRoleUser  = "user"
+)
+
+// auditActions contains all actions recorded in the audit log.
+// Most actions are named after the form target causing them.
+var auditActions = []string{
+	"login",
+	"loginfailed",
+	"logout",
+	"publish",
+	"schedule",
+	"submit",
+	"reviewapprove",
+	"reviewreject",
+	"scheduleedit",
+	"schedulecancel",
+	"edit",
+	"retract",
+	"resend",
+	"recurringadd",
+	"recurringpause",
+	"recurringskip",
+	"recurringend",
+	"categoryadd",
+	"categorydelete",
+	"templateadd",
+	"templateedit",
+	"templatedelete",
+	"config",
+	"reload",
+}
+
+// audit adds an entry for the person sending the request to the audit log.
+// The caller must not hold a.l.
+func (a *announcement) audit(r *http.Request, action, details string) {
+	role := ""
+	loggedin, admin := server.GetLogin(a.Key, r)
+	switch {
+	case admin:
+		role = auditRoleAdmin
+	case loggedin:
+		role = auditRoleUser
+	}
+	a.auditAs(r, role, server.GetIdentity(a.Key, r), action, details)
+}
+
+// auditAs adds an entry with the given role and identity to the audit log.
+// This is needed when the login state of the request does not match the person, e.g. during login.
+// The caller must not hold a.l.
+func (a *announcement) auditAs(r *http.Request, role, identity, action, details string) {
+	e := registry.AuditEntry{
+		Time:     time.Now(),
+		Role:     role,
+		Identity: identity,
+		IP:       helper.GetRealIP(r),
+		Action:   action,
+		Details:  details,
+	}
+	err := registry.CurrentDataSafe.AddAuditEntry(a.Key, e)
+	if err != nil {
+		log.Printf("audit (%s): %s", a.Key, err.Error())
+	}
+}
+
+// auditLoginFailedInterval is the minimal time between two entries for failed logins from the same IP address.
+// Further failed logins in this time are counted and reported in the next entry.
+const auditLoginFailedInterval = 10 * time.Minute
+
+// auditLoginFailedForget is the time after which uncounted failed logins of an IP address are forgotten.
+const auditLoginFailedForget = 24 * time.Hour
+
+// failedLogin holds the last entry for failed logins from an IP address and the number of failed logins since then.
+type failedLogin struct {
+	recorded   time.Time
+	suppressed int
+}
+
+var (
+	failedLogins      = make(map[string]failedLogin)
+	failedLoginsMutex = sync.Mutex{}
+)
+
+// auditLoginFailed adds an entry for a failed login to the audit log.
+// Only one entry per key and IP address is added in auditLoginFailedInterval, so failed logins can not flood the audit log.
+// The caller must not hold a.l.
+func (a *announcement) auditLoginFailed(r *http.Request) {
+	ip := helper.GetRealIP(r)
+	id := strings.Join([]string{a.Key, ip}, "\n")
+	now := time.Now()
+
+	failedLoginsMutex.Lock()
+	last, ok := failedLogins[id]
+	if ok && now.Sub(last.recorded) < auditLoginFailedInterval {
+		last.suppressed++
+		failedLogins[id] = last
+		failedLoginsMutex.Unlock()
+		return
+	}
+	for k, v := range failedLogins {
+		if d := now.Sub(v.recorded); d >= auditLoginFailedForget || (d >= auditLoginFailedInterval && v.suppressed == 0) {
+			delete(failedLogins, k)
+		}
+	}
+	failedLogins[id] = failedLogin{recorded: now}
+	failedLoginsMutex.Unlock()
+
+	details := ""
+	if ok && last.suppressed > 0 && now.Sub(last.recorded) < auditLoginFailedForget {
+		details = fmt.Sprintf("%d further failed logins since %s", last.suppressed, last.recorded.Format(time.RFC3339))
+	}
+	a.auditAs(r, "", "", "loginfailed", details)
+}
+
+// settingsDiff returns a human readable description of all settings which differ between before and after.
+// Values of secret settings are masked.
+func settingsDiff(before, after []registry.Setting) string {
+	old := make(map[string]registry.Setting, len(before))
+	for i := range before {
+		old[before[i].Name] = before[i]
+	}
+
+	var diff []string
+	for i := range after {
+		o := old[after[i].Name]
+		if o.Value == after[i].Value {
+			continue
+		}
+		if after[i].Secret || o.Secret {
+			diff = append(diff, fmt.Sprintf("%s: %s → %s", after[i].Name, maskSetting(o.Value), maskSetting(after[i].Value)))
+			continue
+		}
+		diff = append(diff, fmt.Sprintf("%s: %q → %q", after[i].Name, o.Value, after[i].Value))
+	}
+	return strings.Join(diff, "\n")
+}
+
+// maskSetting hides the value of a secret setting. Only whether the setting is empty is kept.
+func maskSetting(s string) string {
+	if s == "" {
+		return `""`
+	}
+	return "********"
+}
+
+// filterAudit returns all entries of the audit log matching the filter given in the query of the request (newest first).
+func (a *announcement) filterAudit(r *http.Request) ([]registry.AuditEntry, templates.AuditFilter, error) {
+	f := templates.AuditFilter{
+		Action:   r.URL.Query().Get("action"),
+		Identity: r.URL.Query().Get("identity"),
+		From:     r.URL.Query().Get("from"),
+		To:       r.URL.Query().Get("to"),
+	}
+
+	q := registry.AuditQuery{Action: f.Action}
+	var err error
+	q.From, q.To, err = parseDateRange(f.From, f.To)
+	if err != nil {
+		return nil, f, err
+	}
+
+	entries, err := registry.CurrentDataSafe.GetAuditEntries(a.Key, q)
+	if err != nil {
+		return nil, f, err
+	}
+
+	identity := strings.ToLower(f.Identity)
+	result := make([]registry.AuditEntry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		if identity != "" && !strings.Contains(strings.ToLower(entries[i].Identity), identity) {
+			continue
+		}
+		result = append(result, entries[i])
+	}
+	return result, f, nil
+}
+
+// auditHandle shows the filtered audit log to admins.
+func (a *announcement) auditHandle(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	_, admin := server.GetLogin(a.Key, r)
+	if !admin {
+		rw.WriteHeader(http.StatusForbidden)
+		t := templates.TextTemplateStruct{Text: "403 Forbidden", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+
+	entries, filter, err := a.filterAudit(r)
+	if err != nil {
+		log.Printf("announcement audit (%s): %s", a.Key, err.Error())
+		rw.WriteHeader(http.StatusBadRequest)
+		t := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+
+	td := templates.AuditTemplateStruct{
+		Key:              a.Key,
+		ShortDescription: a.ShortDescription,
+		Translation:      translation.GetDefaultTranslation(),
+		Entries:          entries,
+		Actions:          auditActions,
+		Filter:           filter,
+	}
+	err = templates.AuditTemplate.Execute(rw, td)
+	if err != nil {
+		log.Printf("announcement audit template (%s): %s", a.Key, err.Error())
+	}
+}
+
+// auditExportHandle returns the filtered audit log as JSON to admins.
+func (a *announcement) auditExportHandle(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	_, admin := server.GetLogin(a.Key, r)
+	if !admin {
+		rw.WriteHeader(http.StatusForbidden)
+		t := templates.TextTemplateStruct{Text: "403 Forbidden", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+
+	entries, _, err := a.filterAudit(r)
+	if err != nil {
+		log.Printf("announcement audit export (%s): %s", a.Key, err.Error())
+		rw.WriteHeader(http.StatusBadRequest)
+		t := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"audit-%s.json\"", a.Key))
+	enc := json.NewEncoder(rw)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(entries)
+	if err != nil {
+		log.Printf("announcement audit export (%s): %s", a.Key, err.Error())
+	}
+}
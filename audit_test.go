@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/Top-Ranger/announcementgo/registry"
+)
+
+func TestSettingsDiff(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after []registry.Setting
+		want          string
+	}{
+		{
+			name:   "unchanged",
+			before: []registry.Setting{{Name: "server", Value: "mail.example.com"}},
+			after:  []registry.Setting{{Name: "server", Value: "mail.example.com"}},
+			want:   "",
+		},
+		{
+			name:   "changed",
+			before: []registry.Setting{{Name: "server", Value: "mail.example.com"}, {Name: "port", Value: "25"}},
+			after:  []registry.Setting{{Name: "server", Value: "mail.example.com"}, {Name: "port", Value: "587"}},
+			want:   `port: "25" → "587"`,
+		},
+		{
+			name:   "added",
+			before: nil,
+			after:  []registry.Setting{{Name: "server", Value: "mail.example.com"}},
+			want:   `server: "" → "mail.example.com"`,
+		},
+		{
+			name:   "secret changed",
+			before: []registry.Setting{{Name: "password", Value: "old", Secret: true}},
+			after:  []registry.Setting{{Name: "password", Value: "new", Secret: true}},
+			want:   "password: ******** → ********",
+		},
+		{
+			name:   "secret set",
+			before: []registry.Setting{{Name: "token", Value: "", Secret: true}},
+			after:  []registry.Setting{{Name: "token", Value: "secret", Secret: true}},
+			want:   `token: "" → ********`,
+		},
+		{
+			name:   "secret removed",
+			before: []registry.Setting{{Name: "token", Value: "secret", Secret: true}},
+			after:  []registry.Setting{{Name: "token", Value: "", Secret: true}},
+			want:   `token: ******** → ""`,
+		},
+		{
+			name:   "multiple",
+			before: []registry.Setting{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}},
+			after:  []registry.Setting{{Name: "a", Value: "3"}, {Name: "b", Value: "4"}},
+			want:   "a: \"1\" → \"3\"\nb: \"2\" → \"4\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := settingsDiff(tt.before, tt.after)
+			if got != tt.want {
+				t.Errorf("settingsDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
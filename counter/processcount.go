@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020 Marcus Soll
+// Copyright 2020,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -16,6 +16,7 @@
 package counter
 
 import (
+	"context"
 	"sync"
 	"time"
 )
@@ -37,6 +38,23 @@ func EndProcess() {
 	processCount--
 }
 
+// WaitProcessesContext waits like WaitProcesses, but returns ctx.Err() if ctx is done before all processes have ended.
+func WaitProcessesContext(ctx context.Context) error {
+	for {
+		processCountMutex.Lock()
+		r := processCount == 0
+		processCountMutex.Unlock()
+		if r {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(1 * time.Second):
+		}
+	}
+}
+
 // WaitProcesses blocks until all processes have finished.
 func WaitProcesses() {
 	for {
@@ -0,0 +1,274 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/Top-Ranger/announcementgo/helper"
+	"github.com/Top-Ranger/announcementgo/registry"
+	"github.com/Top-Ranger/announcementgo/server"
+	"github.com/Top-Ranger/announcementgo/templates"
+	"github.com/Top-Ranger/announcementgo/translation"
+)
+
+// dashboardKey is the path of the super-admin dashboard. It can not be used as a key while the dashboard is enabled.
+const dashboardKey = "admin"
+
+// dashboardErrors is the maximum number of recent errors shown per key.
+const dashboardErrors = 5
+
+// dashboardEnabled returns whether super-admin passwords are configured.
+func dashboardEnabled() bool {
+	return len(config.SuperAdminPassword) != 0
+}
+
+// initialiseDashboard registers all handlers of the super-admin dashboard.
+func initialiseDashboard() error {
+	if !registry.PasswordMethodExists(config.SuperAdminPasswordMethod) {
+		return fmt.Errorf("dashboard: password method '%s' not known", config.SuperAdminPasswordMethod)
+	}
+
+	err := server.AddHandle(dashboardKey, "", dashboardHandle)
+	if err != nil {
+		return err
+	}
+	err = server.AddHandle(dashboardKey, "login", dashboardLoginHandle)
+	if err != nil {
+		return err
+	}
+	err = server.AddHandle(dashboardKey, "logout", func(rw http.ResponseWriter, r *http.Request) {
+		server.RemoveLoginCookie(dashboardKey, rw, r)
+		http.Redirect(rw, r, fmt.Sprintf("/%s", dashboardKey), http.StatusSeeOther)
+	})
+	if err != nil {
+		return err
+	}
+	err = server.AddHandle(dashboardKey, "enter", dashboardEnterHandle)
+	if err != nil {
+		return err
+	}
+	return server.AddHandle(dashboardKey, "reload", dashboardReloadHandle)
+}
+
+// dashboardHandle shows the state of all keys to super-admins and the login otherwise.
+func dashboardHandle(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	tl := translation.GetDefaultTranslation()
+
+	_, admin := server.GetLogin(dashboardKey, r)
+	if !admin {
+		td := templates.LoginTemplateStruct{
+			Key:              dashboardKey,
+			ShortDescription: tl.Dashboard,
+			Translation:      tl,
+		}
+		err := templates.LoginTemplate.Execute(rw, td)
+		if err != nil {
+			log.Printf("dashboard login template: %s", err.Error())
+		}
+		return
+	}
+
+	knownKeysLock.Lock()
+	announcements := make([]*announcement, 0, len(knownKeys))
+	for _, a := range knownKeys {
+		announcements = append(announcements, a)
+	}
+	knownKeysLock.Unlock()
+	sort.Slice(announcements, func(i, j int) bool { return announcements[i].Key < announcements[j].Key })
+
+	td := templates.DashboardTemplateStruct{
+		Translation: tl,
+		Identity:    server.GetIdentity(dashboardKey, r),
+		Keys:        make([]templates.DashboardKey, 0, len(announcements)),
+	}
+	for i := range announcements {
+		td.Keys = append(td.Keys, announcements[i].dashboard())
+	}
+
+	err := templates.DashboardTemplate.Execute(rw, td)
+	if err != nil {
+		log.Printf("dashboard template: %s", err.Error())
+	}
+}
+
+// dashboard returns the state of the announcement for the dashboard.
+// The caller must not hold a.l.
+func (a *announcement) dashboard() templates.DashboardKey {
+	d := templates.DashboardKey{
+		Key:              a.Key,
+		ShortDescription: a.ShortDescription,
+		Plugins:          make([]templates.DashboardPlugin, 0, len(a.Plugins)),
+	}
+
+	a.l.Lock()
+	loaded := make(map[string]registry.Plugin, len(a.plugins))
+	for i := range a.plugins {
+		loaded[a.pluginNames[i]] = a.plugins[i]
+	}
+	notLoaded := make(map[string]string, len(a.notLoaded))
+	for k, v := range a.notLoaded {
+		notLoaded[k] = v
+	}
+	for i := len(a.messages) - 1; i >= 0 && len(d.Errors) < dashboardErrors; i-- {
+		if a.messages[i].Error {
+			d.Errors = append(d.Errors, a.messages[i].Text)
+		}
+	}
+	a.l.Unlock()
+
+	// Plugins are queried without holding a.l since they might wait for sending messages
+	for i := range a.Plugins {
+		p := templates.DashboardPlugin{Name: a.Plugins[i], NotLoaded: notLoaded[a.Plugins[i]], Queued: -1}
+		if q, ok := loaded[a.Plugins[i]].(registry.QueuedPlugin); ok {
+			p.Queued = q.Queued()
+		}
+		d.Plugins = append(d.Plugins, p)
+	}
+
+	last, err := registry.CurrentDataSafe.GetLastAnnouncements(a.Key, 1)
+	if err != nil {
+		log.Printf("dashboard (%s): %s", a.Key, err.Error())
+	} else if len(last) != 0 {
+		d.LastPublished = last[0].Time
+	}
+	return d
+}
+
+// dashboardLoginHandle logs in super-admins.
+func dashboardLoginHandle(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusBadRequest)
+		t := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		t := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+
+	password := r.Form.Get("password")
+	if password == "" {
+		rw.WriteHeader(http.StatusForbidden)
+		t := templates.TextTemplateStruct{Text: "403 Forbidden", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+
+	for i := range config.SuperAdminPassword {
+		ok, err := registry.ComparePasswords(config.SuperAdminPasswordMethod, password, config.SuperAdminPassword[i].Password)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			t := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
+			templates.TextTemplate.Execute(rw, t)
+			return
+		}
+
+		if ok {
+			err := server.SetLoginCookie(dashboardKey, true, config.SuperAdminPassword[i].Name, rw, r)
+			if err != nil {
+				rw.WriteHeader(http.StatusInternalServerError)
+				t := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
+				templates.TextTemplate.Execute(rw, t)
+				return
+			}
+			log.Printf("dashboard: login from %s", helper.GetRealIP(r))
+			http.Redirect(rw, r, fmt.Sprintf("/%s", dashboardKey), http.StatusSeeOther)
+			return
+		}
+	}
+
+	if config.LogFailedLogin {
+		log.Printf("Failed dashboard login from %s", helper.GetRealIP(r))
+	}
+	rw.WriteHeader(http.StatusForbidden)
+	t := templates.TextTemplateStruct{Text: "403 Forbidden", Translation: translation.GetDefaultTranslation()}
+	templates.TextTemplate.Execute(rw, t)
+}
+
+// dashboardEnterHandle logs a super-admin in as admin of the requested key.
+// The login is recorded in the audit log of the key.
+func dashboardEnterHandle(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusBadRequest)
+		t := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+	_, admin := server.GetLogin(dashboardKey, r)
+	if !admin {
+		rw.WriteHeader(http.StatusForbidden)
+		t := templates.TextTemplateStruct{Text: "403 Forbidden", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		t := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+
+	knownKeysLock.Lock()
+	a, ok := knownKeys[r.Form.Get("key")]
+	knownKeysLock.Unlock()
+	if !ok {
+		rw.WriteHeader(http.StatusNotFound)
+		t := templates.TextTemplateStruct{Text: "404 Not Found", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+
+	identity := server.GetIdentity(dashboardKey, r)
+	err = server.SetLoginCookie(a.Key, true, identity, rw, r)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		t := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+	a.auditAs(r, auditRoleAdmin, identity, "login", "super-admin dashboard")
+	http.Redirect(rw, r, fmt.Sprintf("/%s", a.Key), http.StatusSeeOther)
+}
+
+// dashboardReloadHandle reloads the configuration of all announcements for super-admins.
+func dashboardReloadHandle(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if r.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		t := templates.TextTemplateStruct{Text: "405 Method Not Allowed", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+	_, admin := server.GetLogin(dashboardKey, r)
+	if !admin {
+		rw.WriteHeader(http.StatusForbidden)
+		t := templates.TextTemplateStruct{Text: "403 Forbidden", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+
+	writeReloadResult(rw, reloadAnnouncements(), dashboardKey, true)
+}
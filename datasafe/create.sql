@@ -1,4 +1,10 @@
 CREATE DATABASE announcementgo;
-CREATE TABLE announcementgo.announcement (id BIGINT UNSIGNED AUTO_INCREMENT, k VARCHAR(600) CHARACTER SET latin1 NOT NULL, header LONGTEXT NOT NULL, message LONGTEXT NOT NULL, time DATETIME NOT NULL, PRIMARY KEY(id));
+CREATE TABLE announcementgo.announcement (id BIGINT UNSIGNED AUTO_INCREMENT, k VARCHAR(600) CHARACTER SET latin1 NOT NULL, header LONGTEXT NOT NULL, message LONGTEXT NOT NULL, time DATETIME NOT NULL, edited DATETIME NULL, retracted DATETIME NULL, expires DATETIME NULL, priority INT NOT NULL DEFAULT 0, author VARCHAR(600) NOT NULL DEFAULT '', variants LONGTEXT NULL, categories LONGTEXT NULL, attachments LONGTEXT NULL, plugins LONGTEXT NULL, PRIMARY KEY(id));
 CREATE TABLE announcementgo.config (k VARCHAR(600) CHARACTER SET latin1 NOT NULL, plugin VARCHAR(600) CHARACTER SET latin1 NOT NULL, data LONGBLOB, PRIMARY KEY(k, plugin));
+CREATE TABLE announcementgo.attachment (id VARCHAR(64) CHARACTER SET latin1 NOT NULL, k VARCHAR(600) CHARACTER SET latin1 NOT NULL, data LONGBLOB NOT NULL, PRIMARY KEY(id));
+CREATE TABLE announcementgo.revision (id BIGINT UNSIGNED AUTO_INCREMENT, announcement BIGINT UNSIGNED NOT NULL, header LONGTEXT NOT NULL, message LONGTEXT NOT NULL, time DATETIME NOT NULL, edited DATETIME NULL, retracted DATETIME NULL, expires DATETIME NULL, priority INT NOT NULL DEFAULT 0, author VARCHAR(600) NOT NULL DEFAULT '', variants LONGTEXT NULL, categories LONGTEXT NULL, attachments LONGTEXT NULL, plugins LONGTEXT NULL, PRIMARY KEY(id));
+CREATE TABLE announcementgo.audit (id BIGINT UNSIGNED AUTO_INCREMENT, k VARCHAR(600) CHARACTER SET latin1 NOT NULL, time DATETIME NOT NULL, role VARCHAR(20) NOT NULL, identity LONGTEXT NOT NULL, ip LONGTEXT NOT NULL, action VARCHAR(100) NOT NULL, details LONGTEXT NOT NULL, PRIMARY KEY(id));
+CREATE TABLE announcementgo.delivery (announcement BIGINT UNSIGNED NOT NULL, k VARCHAR(600) CHARACTER SET latin1 NOT NULL, plugin VARCHAR(600) CHARACTER SET latin1 NOT NULL, state INT NOT NULL, recipients INT NOT NULL, sent INT NOT NULL, failed INT NOT NULL, error LONGTEXT NOT NULL, updated DATETIME NOT NULL, PRIMARY KEY(announcement, plugin));
 CREATE INDEX k ON announcementgo.announcement (k);
+CREATE INDEX announcement ON announcementgo.revision (announcement);
+CREATE INDEX audit_k ON announcementgo.audit (k);
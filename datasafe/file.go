@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020 Marcus Soll
+// Copyright 2020,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -24,8 +24,10 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/Top-Ranger/announcementgo/counter"
+	"github.com/Top-Ranger/announcementgo/helper"
 	"github.com/Top-Ranger/announcementgo/registry"
 )
 
@@ -33,6 +35,8 @@ func init() {
 	f := new(file)
 	f.mutex = new(sync.Mutex)
 	f.path = "./data/"
+	f.index = make(map[string][]fileIndexEntry)
+	f.cache = make(map[string][]registry.Announcement)
 
 	err := registry.RegisterDataSafe(f, "file")
 	if err != nil {
@@ -43,6 +47,48 @@ func init() {
 type file struct {
 	path  string
 	mutex *sync.Mutex
+	index map[string][]fileIndexEntry
+	cache map[string][]registry.Announcement
+}
+
+// fileIndexEntry holds the searchable data of a single announcement.
+// The index of the entry matches the index of the announcement.
+type fileIndexEntry struct {
+	time      time.Time
+	text      string
+	retracted bool
+	expires   time.Time
+}
+
+// newFileIndex returns the search index of a.
+func newFileIndex(a []registry.Announcement) []fileIndexEntry {
+	index := make([]fileIndexEntry, len(a))
+	for i := range a {
+		index[i] = fileIndexEntry{
+			time:      a[i].Time,
+			text:      strings.ToLower(strings.Join([]string{a[i].Header, a[i].Message}, "\n")),
+			retracted: !a[i].Retracted.IsZero(),
+			expires:   a[i].Expires,
+		}
+	}
+	return index
+}
+
+// matches returns whether the entry matches the query at time now. text must be the lower case text of the query.
+func (e fileIndexEntry) matches(q registry.AnnouncementQuery, text string, now time.Time) bool {
+	if q.HideRetracted && e.retracted {
+		return false
+	}
+	if q.HideExpired && !e.expires.IsZero() && e.expires.Before(now) {
+		return false
+	}
+	if !q.From.IsZero() && e.time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !e.time.Before(q.To) {
+		return false
+	}
+	return strings.Contains(e.text, text)
 }
 
 func (f *file) InitialiseDatasafe(config []byte) error {
@@ -59,6 +105,22 @@ func (f *file) InitialiseDatasafe(config []byte) error {
 	if err != nil {
 		return err
 	}
+	err = os.MkdirAll(filepath.Join(f.path, "revisions"), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Join(f.path, "attachments"), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Join(f.path, "audit"), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Join(f.path, "delivery"), os.ModePerm)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -149,7 +211,71 @@ func (f *file) GetAllAnnouncements(key string) ([]registry.Announcement, error)
 	defer counter.EndProcess()
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
-	return f.internalLoad(key)
+
+	a, err := f.internalLoad(key)
+	if err != nil {
+		return nil, err
+	}
+	return append([]registry.Announcement(nil), a...), nil
+}
+
+func (f *file) GetLastAnnouncements(key string, n int) ([]registry.Announcement, error) {
+	if n <= 0 {
+		return []registry.Announcement{}, nil
+	}
+
+	counter.StartProcess()
+	defer counter.EndProcess()
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	a, err := f.internalLoad(key)
+	if err != nil {
+		return nil, err
+	}
+	if n < len(a) {
+		a = a[len(a)-n:]
+	}
+	return append([]registry.Announcement{}, a...), nil
+}
+
+func (f *file) GetAnnouncementsSince(key, id string) ([]registry.Announcement, error) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+
+	i := 0
+	if id != "" {
+		var err error
+		i, err = strconv.Atoi(id)
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 {
+			return nil, fmt.Errorf("unknown id %s", id)
+		}
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	a, err := f.internalLoad(key)
+	if err != nil {
+		return nil, err
+	}
+	if i > len(a) {
+		i = len(a)
+	}
+	return append([]registry.Announcement{}, a[i:]...), nil
+}
+
+func (f *file) CountAnnouncements(key string) (int, error) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	a, err := f.internalLoad(key)
+	return len(a), err
 }
 
 func (f *file) GetAnnouncementKeys(key string) ([]string, error) {
@@ -169,15 +295,258 @@ func (f *file) GetAnnouncementKeys(key string) ([]string, error) {
 	return s, nil
 }
 
+func (f *file) UpdateAnnouncement(key, id string, announcement registry.Announcement) error {
+	counter.StartProcess()
+	defer counter.EndProcess()
+
+	i, err := strconv.Atoi(id)
+	if err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	a, err := f.internalLoad(key)
+	if err != nil {
+		return err
+	}
+	if i > len(a) || i <= 0 {
+		return fmt.Errorf("unknown id %s", id)
+	}
+
+	r, err := f.internalLoadRevisions(key)
+	if err != nil {
+		return err
+	}
+	if r == nil {
+		r = make(map[string][]registry.Announcement)
+	}
+	r[id] = append(r[id], a[i-1])
+	err = f.internalSaveRevisions(key, r)
+	if err != nil {
+		return err
+	}
+
+	// Do not change the cache before saving
+	a = append([]registry.Announcement(nil), a...)
+	a[i-1] = announcement
+	return f.internalSave(key, a)
+}
+
+func (f *file) GetAnnouncementRevisions(key, id string) ([]registry.Announcement, error) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	r, err := f.internalLoadRevisions(key)
+	if err != nil {
+		return nil, err
+	}
+	return r[id], nil
+}
+
+func (f *file) SearchAnnouncements(key string, q registry.AnnouncementQuery) ([]registry.Announcement, int, error) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var a []registry.Announcement
+	var err error
+	index, ok := f.index[key]
+	if !ok {
+		a, err = f.internalLoad(key)
+		if err != nil {
+			return nil, 0, err
+		}
+		index = newFileIndex(a)
+		f.index[key] = index
+	}
+
+	text := strings.ToLower(q.Text)
+	now := time.Now()
+	matches := make([]int, 0)
+	for i := len(index) - 1; i >= 0; i-- {
+		if index[i].matches(q, text, now) {
+			matches = append(matches, i)
+		}
+	}
+	total := len(matches)
+
+	if q.Offset > 0 {
+		if q.Offset > len(matches) {
+			q.Offset = len(matches)
+		}
+		matches = matches[q.Offset:]
+	}
+	if q.Limit > 0 && q.Limit < len(matches) {
+		matches = matches[:q.Limit]
+	}
+	if len(matches) == 0 {
+		return []registry.Announcement{}, total, nil
+	}
+
+	if a == nil {
+		a, err = f.internalLoad(key)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	result := make([]registry.Announcement, len(matches))
+	for i := range matches {
+		result[i] = a[matches[i]]
+	}
+	return result, total, nil
+}
+
+func (f *file) RetractAnnouncement(key, id string, t time.Time) error {
+	counter.StartProcess()
+	defer counter.EndProcess()
+
+	i, err := strconv.Atoi(id)
+	if err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	a, err := f.internalLoad(key)
+	if err != nil {
+		return err
+	}
+	if i > len(a) || i <= 0 {
+		return fmt.Errorf("unknown id %s", id)
+	}
+	// Do not change the cache before saving
+	a = append([]registry.Announcement(nil), a...)
+	a[i-1].Retracted = t
+	return f.internalSave(key, a)
+}
+
+func (f *file) SaveAttachment(key string, data []byte) (string, error) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if strings.Contains(key, "﷐") {
+		return "", errors.New("Unallowed characters found")
+	}
+	key = strings.ReplaceAll(key, string(os.PathSeparator), "﷐")
+
+	err := os.MkdirAll(filepath.Join(f.path, "attachments", key), os.ModePerm)
+	if err != nil {
+		return "", err
+	}
+	id, err := helper.NewID()
+	if err != nil {
+		return "", err
+	}
+	return id, os.WriteFile(filepath.Join(f.path, "attachments", key, id), data, os.ModePerm)
+}
+
+func (f *file) GetAttachment(key, id string) ([]byte, error) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if strings.Contains(key, "﷐") {
+		return nil, errors.New("Unallowed characters found")
+	}
+	key = strings.ReplaceAll(key, string(os.PathSeparator), "﷐")
+	if id == "" || strings.ContainsAny(id, "./\\") {
+		return nil, fmt.Errorf("unknown attachment %s", id)
+	}
+
+	return os.ReadFile(filepath.Join(f.path, "attachments", key, id))
+}
+
+func (f *file) AddAuditEntry(key string, e registry.AuditEntry) error {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	entries, err := f.internalLoadAudit(key)
+	if err != nil {
+		return err
+	}
+	entries = append(entries, e)
+	return f.internalSaveAudit(key, entries)
+}
+
+func (f *file) GetAuditEntries(key string, q registry.AuditQuery) ([]registry.AuditEntry, error) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	entries, err := f.internalLoadAudit(key)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]registry.AuditEntry, 0, len(entries))
+	for i := range entries {
+		if q.Matches(entries[i]) {
+			result = append(result, entries[i])
+		}
+	}
+	return result, nil
+}
+
+func (f *file) SaveDelivery(key, id string, d registry.Delivery) error {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	deliveries, err := f.internalLoadDelivery(key)
+	if err != nil {
+		return err
+	}
+	if deliveries == nil {
+		deliveries = make(map[string][]registry.Delivery)
+	}
+	for i := range deliveries[id] {
+		if deliveries[id][i].Plugin == d.Plugin {
+			deliveries[id][i] = d
+			return f.internalSaveDelivery(key, deliveries)
+		}
+	}
+	deliveries[id] = append(deliveries[id], d)
+	return f.internalSaveDelivery(key, deliveries)
+}
+
+func (f *file) GetDeliveries(key, id string) ([]registry.Delivery, error) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	deliveries, err := f.internalLoadDelivery(key)
+	if err != nil {
+		return nil, err
+	}
+	return deliveries[id], nil
+}
+
 func (f *file) internalLoad(key string) ([]registry.Announcement, error) {
 	// f must be locked by caller
+	// The returned slice is shared with the cache and must not be modified
+	if a, ok := f.cache[key]; ok {
+		return a, nil
+	}
 	if strings.Contains(key, "﷐") {
 		return nil, errors.New("Unallowed characters found")
 	}
-	key = strings.ReplaceAll(key, string(os.PathSeparator), "﷐")
+	escapedKey := strings.ReplaceAll(key, string(os.PathSeparator), "﷐")
 
 	var a []registry.Announcement
-	file, err := os.Open(filepath.Join(f.path, "announcements", key))
+	file, err := os.Open(filepath.Join(f.path, "announcements", escapedKey))
 	if os.IsNotExist(err) {
 		return nil, nil
 	} else if err != nil {
@@ -186,24 +555,158 @@ func (f *file) internalLoad(key string) ([]registry.Announcement, error) {
 	defer file.Close()
 	dec := gob.NewDecoder(file)
 	err = dec.Decode(&a)
-	return a, err
+	if err != nil {
+		return nil, err
+	}
+	for i := range a {
+		a[i].ID = strconv.Itoa(i + 1)
+	}
+	f.cache[key] = a
+	return a, nil
 }
 
 func (f *file) internalSave(key string, a []registry.Announcement) error {
 	// f must be locked by caller
 	counter.StartProcess()
 	defer counter.EndProcess()
+	// The search index is rebuilt on the next search, the cache is only kept if saving was successful
+	delete(f.index, key)
+	delete(f.cache, key)
 	if strings.Contains(key, "﷐") {
 		return errors.New("Unallowed characters found")
 	}
-	key = strings.ReplaceAll(key, string(os.PathSeparator), "﷐")
+	escapedKey := strings.ReplaceAll(key, string(os.PathSeparator), "﷐")
 
-	file, err := os.Create(filepath.Join(f.path, "announcements", key))
+	file, err := os.Create(filepath.Join(f.path, "announcements", escapedKey))
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 	enc := gob.NewEncoder(file)
 	err = enc.Encode(&a)
+	if err != nil {
+		return err
+	}
+	for i := range a {
+		a[i].ID = strconv.Itoa(i + 1)
+	}
+	f.cache[key] = a
+	return nil
+}
+
+func (f *file) internalLoadRevisions(key string) (map[string][]registry.Announcement, error) {
+	// f must be locked by caller
+	if strings.Contains(key, "﷐") {
+		return nil, errors.New("Unallowed characters found")
+	}
+	key = strings.ReplaceAll(key, string(os.PathSeparator), "﷐")
+
+	var r map[string][]registry.Announcement
+	file, err := os.Open(filepath.Join(f.path, "revisions", key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	dec := gob.NewDecoder(file)
+	err = dec.Decode(&r)
+	return r, err
+}
+
+func (f *file) internalSaveRevisions(key string, r map[string][]registry.Announcement) error {
+	// f must be locked by caller
+	counter.StartProcess()
+	defer counter.EndProcess()
+	if strings.Contains(key, "﷐") {
+		return errors.New("Unallowed characters found")
+	}
+	key = strings.ReplaceAll(key, string(os.PathSeparator), "﷐")
+
+	file, err := os.Create(filepath.Join(f.path, "revisions", key))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	enc := gob.NewEncoder(file)
+	err = enc.Encode(&r)
+	return err
+}
+
+func (f *file) internalLoadAudit(key string) ([]registry.AuditEntry, error) {
+	// f must be locked by caller
+	if strings.Contains(key, "﷐") {
+		return nil, errors.New("Unallowed characters found")
+	}
+	key = strings.ReplaceAll(key, string(os.PathSeparator), "﷐")
+
+	var e []registry.AuditEntry
+	file, err := os.Open(filepath.Join(f.path, "audit", key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	dec := gob.NewDecoder(file)
+	err = dec.Decode(&e)
+	return e, err
+}
+
+func (f *file) internalSaveAudit(key string, e []registry.AuditEntry) error {
+	// f must be locked by caller
+	counter.StartProcess()
+	defer counter.EndProcess()
+	if strings.Contains(key, "﷐") {
+		return errors.New("Unallowed characters found")
+	}
+	key = strings.ReplaceAll(key, string(os.PathSeparator), "﷐")
+
+	file, err := os.Create(filepath.Join(f.path, "audit", key))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	enc := gob.NewEncoder(file)
+	err = enc.Encode(&e)
+	return err
+}
+
+func (f *file) internalLoadDelivery(key string) (map[string][]registry.Delivery, error) {
+	// f must be locked by caller
+	if strings.Contains(key, "﷐") {
+		return nil, errors.New("Unallowed characters found")
+	}
+	key = strings.ReplaceAll(key, string(os.PathSeparator), "﷐")
+
+	var d map[string][]registry.Delivery
+	file, err := os.Open(filepath.Join(f.path, "delivery", key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	dec := gob.NewDecoder(file)
+	err = dec.Decode(&d)
+	return d, err
+}
+
+func (f *file) internalSaveDelivery(key string, d map[string][]registry.Delivery) error {
+	// f must be locked by caller
+	counter.StartProcess()
+	defer counter.EndProcess()
+	if strings.Contains(key, "﷐") {
+		return errors.New("Unallowed characters found")
+	}
+	key = strings.ReplaceAll(key, string(os.PathSeparator), "﷐")
+
+	file, err := os.Create(filepath.Join(f.path, "delivery", key))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	enc := gob.NewEncoder(file)
+	err = enc.Encode(&d)
 	return err
 }
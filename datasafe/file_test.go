@@ -0,0 +1,371 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasafe
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Top-Ranger/announcementgo/registry"
+)
+
+const testKey = "test"
+
+// testStart is the publication time of the first test announcement.
+var testStart = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestFile returns an initialised file data safe in a temporary directory.
+func newTestFile(t *testing.T) *file {
+	t.Helper()
+	f := &file{
+		path:  t.TempDir(),
+		mutex: new(sync.Mutex),
+		index: make(map[string][]fileIndexEntry),
+		cache: make(map[string][]registry.Announcement),
+	}
+	err := f.InitialiseDatasafe(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// saveTestAnnouncements saves n announcements with the headers "1" to "n", published one day apart starting at testStart.
+func saveTestAnnouncements(t *testing.T, f *file, n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		a := registry.Announcement{
+			Header:  strconv.Itoa(i),
+			Message: "Message " + strconv.Itoa(i),
+			Time:    testStart.AddDate(0, 0, i-1),
+		}
+		id, err := f.SaveAnnouncement(testKey, a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != strconv.Itoa(i) {
+			t.Fatalf("SaveAnnouncement returned id %s, want %d", id, i)
+		}
+	}
+}
+
+// headers returns the headers of all announcements.
+func headers(a []registry.Announcement) []string {
+	h := make([]string, len(a))
+	for i := range a {
+		h[i] = a[i].Header
+	}
+	return h
+}
+
+func TestFileGetLastAnnouncements(t *testing.T) {
+	f := newTestFile(t)
+	saveTestAnnouncements(t, f, 5)
+
+	tests := []struct {
+		n    int
+		want []string
+	}{
+		{n: -1, want: []string{}},
+		{n: 0, want: []string{}},
+		{n: 1, want: []string{"5"}},
+		{n: 3, want: []string{"3", "4", "5"}},
+		{n: 5, want: []string{"1", "2", "3", "4", "5"}},
+		{n: 10, want: []string{"1", "2", "3", "4", "5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.n), func(t *testing.T) {
+			a, err := f.GetLastAnnouncements(testKey, tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := headers(a); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetLastAnnouncements(%d) = %v, want %v", tt.n, got, tt.want)
+			}
+		})
+	}
+
+	a, err := f.GetLastAnnouncements("unknown", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 0 {
+		t.Errorf("GetLastAnnouncements of unknown key returned %d announcements", len(a))
+	}
+}
+
+func TestFileGetAnnouncementsSince(t *testing.T) {
+	f := newTestFile(t)
+	saveTestAnnouncements(t, f, 5)
+
+	tests := []struct {
+		id      string
+		want    []string
+		wantErr bool
+	}{
+		{id: "", want: []string{"1", "2", "3", "4", "5"}},
+		{id: "0", want: []string{"1", "2", "3", "4", "5"}},
+		{id: "3", want: []string{"4", "5"}},
+		{id: "5", want: []string{}},
+		{id: "10", want: []string{}},
+		{id: "-1", wantErr: true},
+		{id: "a", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			a, err := f.GetAnnouncementsSince(testKey, tt.id)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("GetAnnouncementsSince(%q) returned no error", tt.id)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := headers(a); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetAnnouncementsSince(%q) = %v, want %v", tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileCountAnnouncements(t *testing.T) {
+	f := newTestFile(t)
+
+	n, err := f.CountAnnouncements(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("CountAnnouncements of empty key = %d, want 0", n)
+	}
+
+	saveTestAnnouncements(t, f, 4)
+	n, err = f.CountAnnouncements(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Errorf("CountAnnouncements = %d, want 4", n)
+	}
+}
+
+func TestFileSearchAnnouncements(t *testing.T) {
+	f := newTestFile(t)
+	saveTestAnnouncements(t, f, 6)
+
+	err := f.RetractAnnouncement(testKey, "2", testStart.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := f.GetAnnouncement(testKey, "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Expires = time.Now().Add(-time.Hour)
+	a.Message = "Expired message"
+	err = f.UpdateAnnouncement(testKey, "3", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err = f.GetAnnouncement(testKey, "4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Expires = time.Now().Add(time.Hour)
+	err = f.UpdateAnnouncement(testKey, "4", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		q         registry.AnnouncementQuery
+		want      []string
+		wantTotal int
+	}{
+		{name: "all", q: registry.AnnouncementQuery{}, want: []string{"6", "5", "4", "3", "2", "1"}, wantTotal: 6},
+		{name: "text in message", q: registry.AnnouncementQuery{Text: "message 5"}, want: []string{"5"}, wantTotal: 1},
+		{name: "text case insensitive", q: registry.AnnouncementQuery{Text: "EXPIRED"}, want: []string{"3"}, wantTotal: 1},
+		{name: "text in header", q: registry.AnnouncementQuery{Text: "6"}, want: []string{"6"}, wantTotal: 1},
+		{name: "no match", q: registry.AnnouncementQuery{Text: "unknown"}, want: []string{}, wantTotal: 0},
+		{name: "from", q: registry.AnnouncementQuery{From: testStart.AddDate(0, 0, 4)}, want: []string{"6", "5"}, wantTotal: 2},
+		{name: "to", q: registry.AnnouncementQuery{To: testStart.AddDate(0, 0, 2)}, want: []string{"2", "1"}, wantTotal: 2},
+		{name: "from and to", q: registry.AnnouncementQuery{From: testStart.AddDate(0, 0, 1), To: testStart.AddDate(0, 0, 3)}, want: []string{"3", "2"}, wantTotal: 2},
+		{name: "hide retracted", q: registry.AnnouncementQuery{HideRetracted: true}, want: []string{"6", "5", "4", "3", "1"}, wantTotal: 5},
+		{name: "hide expired", q: registry.AnnouncementQuery{HideExpired: true}, want: []string{"6", "5", "4", "2", "1"}, wantTotal: 5},
+		{name: "hide retracted and expired", q: registry.AnnouncementQuery{HideRetracted: true, HideExpired: true}, want: []string{"6", "5", "4", "1"}, wantTotal: 4},
+		{name: "limit", q: registry.AnnouncementQuery{Limit: 2}, want: []string{"6", "5"}, wantTotal: 6},
+		{name: "offset", q: registry.AnnouncementQuery{Offset: 4}, want: []string{"2", "1"}, wantTotal: 6},
+		{name: "offset and limit", q: registry.AnnouncementQuery{Offset: 2, Limit: 2}, want: []string{"4", "3"}, wantTotal: 6},
+		{name: "offset after end", q: registry.AnnouncementQuery{Offset: 10, Limit: 2}, want: []string{}, wantTotal: 6},
+		{name: "filter with page", q: registry.AnnouncementQuery{HideRetracted: true, Offset: 1, Limit: 3}, want: []string{"5", "4", "3"}, wantTotal: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, total, err := f.SearchAnnouncements(testKey, tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := headers(a); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SearchAnnouncements(%+v) = %v, want %v", tt.q, got, tt.want)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("SearchAnnouncements(%+v) total = %d, want %d", tt.q, total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestFileSearchAnnouncementsAfterSave(t *testing.T) {
+	f := newTestFile(t)
+	saveTestAnnouncements(t, f, 2)
+
+	// Build the search index before changing the announcements
+	_, total, err := f.SearchAnnouncements(testKey, registry.AnnouncementQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 {
+		t.Fatalf("SearchAnnouncements total = %d, want 2", total)
+	}
+
+	_, err = f.SaveAnnouncement(testKey, registry.Announcement{Header: "new", Message: "new", Time: testStart})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, total, err := f.SearchAnnouncements(testKey, registry.AnnouncementQuery{Text: "new"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(a) != 1 || a[0].ID != "3" {
+		t.Errorf("SearchAnnouncements after saving = %v (total %d), want only announcement 3", headers(a), total)
+	}
+}
+
+func TestFileRevisions(t *testing.T) {
+	f := newTestFile(t)
+	original := registry.Announcement{
+		Header:     "Original",
+		Message:    "Original message",
+		Time:       testStart,
+		Expires:    testStart.AddDate(0, 1, 0),
+		Variants:   []registry.Variant{{Language: "de", Header: "Original (de)", Message: "Originalnachricht"}},
+		Categories: []string{"a"},
+		Author:     "Author",
+	}
+	id, err := f.SaveAnnouncement(testKey, original)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := f.GetAnnouncementRevisions(testKey, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 0 {
+		t.Fatalf("new announcement has %d revisions", len(r))
+	}
+
+	first := original
+	first.Header = "First edit"
+	first.Edited = testStart.AddDate(0, 0, 1)
+	first.Expires = time.Time{}
+	first.Variants = nil
+	err = f.UpdateAnnouncement(testKey, id, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := first
+	second.Header = "Second edit"
+	second.Edited = testStart.AddDate(0, 0, 2)
+	err = f.UpdateAnnouncement(testKey, id, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err = f.GetAnnouncementRevisions(testKey, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := headers(r); !reflect.DeepEqual(got, []string{"Original", "First edit"}) {
+		t.Fatalf("GetAnnouncementRevisions = %v, want oldest first", got)
+	}
+	original.ID = id
+	if !reflect.DeepEqual(r[0], original) {
+		t.Errorf("first revision = %+v, want complete original %+v", r[0], original)
+	}
+
+	current, err := f.GetAnnouncement(testKey, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Header != "Second edit" || !current.Edited.Equal(second.Edited) {
+		t.Errorf("GetAnnouncement after edits = %+v, want second edit", current)
+	}
+
+	err = f.UpdateAnnouncement(testKey, "2", second)
+	if err == nil {
+		t.Error("UpdateAnnouncement of unknown id returned no error")
+	}
+}
+
+func TestFileGetAuditEntries(t *testing.T) {
+	f := newTestFile(t)
+	actions := []string{"login", "publish", "loginfailed", "publish", "logout"}
+	for i := range actions {
+		err := f.AddAuditEntry(testKey, registry.AuditEntry{Time: testStart.AddDate(0, 0, i), Action: actions[i], Details: strconv.Itoa(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		q    registry.AuditQuery
+		want []string
+	}{
+		{name: "all", q: registry.AuditQuery{}, want: []string{"0", "1", "2", "3", "4"}},
+		{name: "action", q: registry.AuditQuery{Action: "publish"}, want: []string{"1", "3"}},
+		{name: "unknown action", q: registry.AuditQuery{Action: "unknown"}, want: []string{}},
+		{name: "from", q: registry.AuditQuery{From: testStart.AddDate(0, 0, 3)}, want: []string{"3", "4"}},
+		{name: "to", q: registry.AuditQuery{To: testStart.AddDate(0, 0, 2)}, want: []string{"0", "1"}},
+		{name: "action and time", q: registry.AuditQuery{Action: "publish", From: testStart.AddDate(0, 0, 2)}, want: []string{"3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := f.GetAuditEntries(testKey, tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, len(e))
+			for i := range e {
+				got[i] = e[i].Details
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetAuditEntries(%+v) = %v, want %v", tt.q, got, tt.want)
+			}
+		})
+	}
+}
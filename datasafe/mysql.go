@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2021,2022 Marcus Soll
+// Copyright 2021,2022,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -17,14 +17,18 @@ package datasafe
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 
 	"github.com/Top-Ranger/announcementgo/counter"
+	"github.com/Top-Ranger/announcementgo/helper"
 	"github.com/Top-Ranger/announcementgo/registry"
 )
 
@@ -46,6 +50,89 @@ var ErrMySQLIDtooLong = errors.New("mysql: id is too long")
 // ErrMySQLNotConfigured is returned when the database is used before it is configured
 var ErrMySQLNotConfigured = errors.New("mysql: usage before configuration is used")
 
+// mysqlAnnouncementColumns holds all columns needed for scanAnnouncement
+const mysqlAnnouncementColumns = "id, header, message, time, edited, retracted, expires, priority, author, variants, categories, attachments, plugins"
+
+// mysqlRevisionColumns are the columns of an announcement stored in a revision, without the id.
+// They must be in the same order as in mysqlAnnouncementColumns.
+const mysqlRevisionColumns = "header, message, time, edited, retracted, expires, priority, author, variants, categories, attachments, plugins"
+
+// mysqlRevisionSelect selects a revision from the table alias r so it can be read by scanAnnouncement.
+const mysqlRevisionSelect = "r.announcement, r.header, r.message, r.time, r.edited, r.retracted, r.expires, r.priority, r.author, r.variants, r.categories, r.attachments, r.plugins"
+
+// mysqlLikeEscaper escapes all wildcards of a LIKE pattern
+var mysqlLikeEscaper = strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_")
+
+type mysqlScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAnnouncement(s mysqlScanner) (registry.Announcement, error) {
+	var a registry.Announcement
+	var id uint64
+	var edited, retracted, expires sql.NullTime
+	var variants, categories, attachments, plugins sql.NullString
+	err := s.Scan(&id, &a.Header, &a.Message, &a.Time, &edited, &retracted, &expires, &a.Priority, &a.Author, &variants, &categories, &attachments, &plugins)
+	if err != nil {
+		return registry.Announcement{}, err
+	}
+	a.ID = strconv.FormatUint(id, 10)
+	if edited.Valid {
+		a.Edited = edited.Time
+	}
+	if retracted.Valid {
+		a.Retracted = retracted.Time
+	}
+	if expires.Valid {
+		a.Expires = expires.Time
+	}
+	if variants.Valid {
+		err = json.Unmarshal([]byte(variants.String), &a.Variants)
+		if err != nil {
+			return registry.Announcement{}, err
+		}
+	}
+	if categories.Valid {
+		err = json.Unmarshal([]byte(categories.String), &a.Categories)
+		if err != nil {
+			return registry.Announcement{}, err
+		}
+	}
+	if attachments.Valid {
+		err = json.Unmarshal([]byte(attachments.String), &a.Attachments)
+		if err != nil {
+			return registry.Announcement{}, err
+		}
+	}
+	if plugins.Valid {
+		err = json.Unmarshal([]byte(plugins.String), &a.Plugins)
+		if err != nil {
+			return registry.Announcement{}, err
+		}
+	}
+	return a, nil
+}
+
+// nullJSON converts v into a JSON encoded sql.NullString, nil is converted to NULL.
+func nullJSON[T any](v []T) (sql.NullString, error) {
+	if v == nil {
+		return sql.NullString{}, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(b), Valid: true}, nil
+}
+
+// nullTime converts t into a sql.NullTime, a zero time is converted to NULL.
+func nullTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t, Valid: true}
+}
+
 type mysql struct {
 	dsn string
 	db  *sql.DB
@@ -128,7 +215,24 @@ func (m *mysql) SaveAnnouncement(key string, announcement registry.Announcement)
 	counter.StartProcess()
 	defer counter.EndProcess()
 
-	r, err := m.db.Exec("INSERT INTO announcement (k, header, message, time ) VALUES (?,?,?,?)", key, announcement.Header, announcement.Message, announcement.Time)
+	variants, err := nullJSON(announcement.Variants)
+	if err != nil {
+		return "", err
+	}
+	categories, err := nullJSON(announcement.Categories)
+	if err != nil {
+		return "", err
+	}
+	attachments, err := nullJSON(announcement.Attachments)
+	if err != nil {
+		return "", err
+	}
+	plugins, err := nullJSON(announcement.Plugins)
+	if err != nil {
+		return "", err
+	}
+
+	r, err := m.db.Exec("INSERT INTO announcement (k, header, message, time, expires, priority, author, variants, categories, attachments, plugins) VALUES (?,?,?,?,?,?,?,?,?,?,?)", key, announcement.Header, announcement.Message, announcement.Time, nullTime(announcement.Expires), announcement.Priority, announcement.Author, variants, categories, attachments, plugins)
 	if err != nil {
 		return "", err
 	}
@@ -151,23 +255,21 @@ func (m *mysql) GetAnnouncement(key, id string) (registry.Announcement, error) {
 	counter.StartProcess()
 	defer counter.EndProcess()
 
-	parsedId, err := strconv.ParseUint(key, 10, 64)
+	parsedId, err := strconv.ParseUint(id, 10, 64)
 	if err != nil {
 		return registry.Announcement{}, err
 	}
 
-	rows, err := m.db.Query("SELECT header, message, time FROM announcement WHERE id=?", parsedId)
+	rows, err := m.db.Query("SELECT "+mysqlAnnouncementColumns+" FROM announcement WHERE id=? AND k=?", parsedId, key)
 	if err != nil {
 		return registry.Announcement{}, err
 	}
 	defer rows.Close()
 
 	if !rows.Next() {
-		return registry.Announcement{}, nil
+		return registry.Announcement{}, fmt.Errorf("unknown id %s", id)
 	}
-	var a registry.Announcement
-	err = rows.Scan(&a.Header, &a.Message, &a.Time)
-	return a, err
+	return scanAnnouncement(rows)
 }
 
 func (m *mysql) GetAllAnnouncements(key string) ([]registry.Announcement, error) {
@@ -182,7 +284,7 @@ func (m *mysql) GetAllAnnouncements(key string) ([]registry.Announcement, error)
 	counter.StartProcess()
 	defer counter.EndProcess()
 
-	rows, err := m.db.Query("SELECT header, message, time FROM announcement WHERE k=? ORDER BY id ASC", key)
+	rows, err := m.db.Query("SELECT "+mysqlAnnouncementColumns+" FROM announcement WHERE k=? ORDER BY id ASC", key)
 	if err != nil {
 		return nil, err
 	}
@@ -190,8 +292,7 @@ func (m *mysql) GetAllAnnouncements(key string) ([]registry.Announcement, error)
 
 	result := make([]registry.Announcement, 0)
 	for rows.Next() {
-		var a registry.Announcement
-		err = rows.Scan(&a.Header, &a.Message, &a.Time)
+		a, err := scanAnnouncement(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -200,6 +301,97 @@ func (m *mysql) GetAllAnnouncements(key string) ([]registry.Announcement, error)
 	return result, err
 }
 
+func (m *mysql) GetLastAnnouncements(key string, n int) ([]registry.Announcement, error) {
+	if m.db == nil {
+		return nil, ErrMySQLNotConfigured
+	}
+
+	if len(key) > MySQLMaxLengthID {
+		return nil, ErrMySQLIDtooLong
+	}
+
+	counter.StartProcess()
+	defer counter.EndProcess()
+
+	if n <= 0 {
+		return []registry.Announcement{}, nil
+	}
+
+	rows, err := m.db.Query("SELECT "+mysqlAnnouncementColumns+" FROM announcement WHERE k=? ORDER BY id DESC LIMIT ?", key, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]registry.Announcement, 0, n)
+	for rows.Next() {
+		a, err := scanAnnouncement(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, a)
+	}
+	for i := 0; i < len(result)/2; i++ {
+		result[i], result[len(result)-1-i] = result[len(result)-1-i], result[i]
+	}
+	return result, rows.Err()
+}
+
+func (m *mysql) GetAnnouncementsSince(key, id string) ([]registry.Announcement, error) {
+	if m.db == nil {
+		return nil, ErrMySQLNotConfigured
+	}
+
+	if len(key) > MySQLMaxLengthID {
+		return nil, ErrMySQLIDtooLong
+	}
+
+	counter.StartProcess()
+	defer counter.EndProcess()
+
+	var parsedId uint64
+	if id != "" {
+		var err error
+		parsedId, err = strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := m.db.Query("SELECT "+mysqlAnnouncementColumns+" FROM announcement WHERE k=? AND id>? ORDER BY id ASC", key, parsedId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]registry.Announcement, 0)
+	for rows.Next() {
+		a, err := scanAnnouncement(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, a)
+	}
+	return result, rows.Err()
+}
+
+func (m *mysql) CountAnnouncements(key string) (int, error) {
+	if m.db == nil {
+		return 0, ErrMySQLNotConfigured
+	}
+
+	if len(key) > MySQLMaxLengthID {
+		return 0, ErrMySQLIDtooLong
+	}
+
+	counter.StartProcess()
+	defer counter.EndProcess()
+
+	var n int
+	err := m.db.QueryRow("SELECT COUNT(*) FROM announcement WHERE k=?", key).Scan(&n)
+	return n, err
+}
+
 func (m *mysql) GetAnnouncementKeys(key string) ([]string, error) {
 	if m.db == nil {
 		return nil, ErrMySQLNotConfigured
@@ -212,7 +404,7 @@ func (m *mysql) GetAnnouncementKeys(key string) ([]string, error) {
 	counter.StartProcess()
 	defer counter.EndProcess()
 
-	rows, err := m.db.Query("SELECT id WHERE k=? ORDER BY id ASC", key)
+	rows, err := m.db.Query("SELECT id FROM announcement WHERE k=? ORDER BY id ASC", key)
 	if err != nil {
 		return nil, err
 	}
@@ -229,3 +421,365 @@ func (m *mysql) GetAnnouncementKeys(key string) ([]string, error) {
 	}
 	return result, err
 }
+
+func (m *mysql) UpdateAnnouncement(key, id string, announcement registry.Announcement) error {
+	if m.db == nil {
+		return ErrMySQLNotConfigured
+	}
+
+	if len(key) > MySQLMaxLengthID {
+		return ErrMySQLIDtooLong
+	}
+
+	counter.StartProcess()
+	defer counter.EndProcess()
+
+	parsedId, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	r, err := tx.Exec("INSERT INTO revision (announcement, "+mysqlRevisionColumns+") SELECT id, "+mysqlRevisionColumns+" FROM announcement WHERE id=? AND k=?", parsedId, key)
+	if err != nil {
+		return err
+	}
+	n, err := r.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("unknown id %s", id)
+	}
+
+	variants, err := nullJSON(announcement.Variants)
+	if err != nil {
+		return err
+	}
+	categories, err := nullJSON(announcement.Categories)
+	if err != nil {
+		return err
+	}
+	attachments, err := nullJSON(announcement.Attachments)
+	if err != nil {
+		return err
+	}
+	plugins, err := nullJSON(announcement.Plugins)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE announcement SET header=?, message=?, time=?, edited=?, expires=?, priority=?, author=?, variants=?, categories=?, attachments=?, plugins=? WHERE id=? AND k=?", announcement.Header, announcement.Message, announcement.Time, nullTime(announcement.Edited), nullTime(announcement.Expires), announcement.Priority, announcement.Author, variants, categories, attachments, plugins, parsedId, key)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *mysql) GetAnnouncementRevisions(key, id string) ([]registry.Announcement, error) {
+	if m.db == nil {
+		return nil, ErrMySQLNotConfigured
+	}
+
+	if len(key) > MySQLMaxLengthID {
+		return nil, ErrMySQLIDtooLong
+	}
+
+	counter.StartProcess()
+	defer counter.EndProcess()
+
+	parsedId, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.Query("SELECT "+mysqlRevisionSelect+" FROM revision r JOIN announcement a ON r.announcement=a.id WHERE r.announcement=? AND a.k=? ORDER BY r.id ASC", parsedId, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]registry.Announcement, 0)
+	for rows.Next() {
+		a, err := scanAnnouncement(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, a)
+	}
+	return result, rows.Err()
+}
+
+func (m *mysql) SearchAnnouncements(key string, q registry.AnnouncementQuery) ([]registry.Announcement, int, error) {
+	if m.db == nil {
+		return nil, 0, ErrMySQLNotConfigured
+	}
+
+	if len(key) > MySQLMaxLengthID {
+		return nil, 0, ErrMySQLIDtooLong
+	}
+
+	counter.StartProcess()
+	defer counter.EndProcess()
+
+	where := []string{"k=?"}
+	args := []any{key}
+	if q.Text != "" {
+		pattern := strings.Join([]string{"%", mysqlLikeEscaper.Replace(q.Text), "%"}, "")
+		where = append(where, "(header LIKE ? OR message LIKE ?)")
+		args = append(args, pattern, pattern)
+	}
+	if !q.From.IsZero() {
+		where = append(where, "time>=?")
+		args = append(args, q.From)
+	}
+	if !q.To.IsZero() {
+		where = append(where, "time<?")
+		args = append(args, q.To)
+	}
+	if q.HideRetracted {
+		where = append(where, "retracted IS NULL")
+	}
+	if q.HideExpired {
+		where = append(where, "(expires IS NULL OR expires>=?)")
+		args = append(args, time.Now())
+	}
+	condition := strings.Join(where, " AND ")
+
+	var total int
+	err := m.db.QueryRow("SELECT COUNT(*) FROM announcement WHERE "+condition, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	limit := uint64(math.MaxInt64)
+	if q.Limit > 0 {
+		limit = uint64(q.Limit)
+	}
+	offset := 0
+	if q.Offset > 0 {
+		offset = q.Offset
+	}
+	rows, err := m.db.Query("SELECT "+mysqlAnnouncementColumns+" FROM announcement WHERE "+condition+" ORDER BY id DESC LIMIT ? OFFSET ?", append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	result := make([]registry.Announcement, 0)
+	for rows.Next() {
+		a, err := scanAnnouncement(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		result = append(result, a)
+	}
+	return result, total, rows.Err()
+}
+
+func (m *mysql) RetractAnnouncement(key, id string, t time.Time) error {
+	if m.db == nil {
+		return ErrMySQLNotConfigured
+	}
+
+	if len(key) > MySQLMaxLengthID {
+		return ErrMySQLIDtooLong
+	}
+
+	counter.StartProcess()
+	defer counter.EndProcess()
+
+	parsedId, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	r, err := m.db.Exec("UPDATE announcement SET retracted=? WHERE id=? AND k=?", t, parsedId, key)
+	if err != nil {
+		return err
+	}
+	n, err := r.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("unknown id %s", id)
+	}
+	return nil
+}
+
+func (m *mysql) SaveAttachment(key string, data []byte) (string, error) {
+	if m.db == nil {
+		return "", ErrMySQLNotConfigured
+	}
+
+	if len(key) > MySQLMaxLengthID {
+		return "", ErrMySQLIDtooLong
+	}
+
+	counter.StartProcess()
+	defer counter.EndProcess()
+
+	id, err := helper.NewID()
+	if err != nil {
+		return "", err
+	}
+	_, err = m.db.Exec("INSERT INTO attachment (id, k, data) VALUES (?,?,?)", id, key, data)
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (m *mysql) GetAttachment(key, id string) ([]byte, error) {
+	if m.db == nil {
+		return nil, ErrMySQLNotConfigured
+	}
+
+	if len(key) > MySQLMaxLengthID {
+		return nil, ErrMySQLIDtooLong
+	}
+
+	counter.StartProcess()
+	defer counter.EndProcess()
+
+	rows, err := m.db.Query("SELECT data FROM attachment WHERE id=? AND k=?", id, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, fmt.Errorf("unknown attachment %s", id)
+	}
+	var b []byte
+	err = rows.Scan(&b)
+	return b, err
+}
+
+func (m *mysql) AddAuditEntry(key string, e registry.AuditEntry) error {
+	if m.db == nil {
+		return ErrMySQLNotConfigured
+	}
+
+	if len(key) > MySQLMaxLengthID {
+		return ErrMySQLIDtooLong
+	}
+
+	counter.StartProcess()
+	defer counter.EndProcess()
+
+	_, err := m.db.Exec("INSERT INTO audit (k, time, role, identity, ip, action, details) VALUES (?,?,?,?,?,?,?)", key, e.Time, e.Role, e.Identity, e.IP, e.Action, e.Details)
+	return err
+}
+
+func (m *mysql) GetAuditEntries(key string, q registry.AuditQuery) ([]registry.AuditEntry, error) {
+	if m.db == nil {
+		return nil, ErrMySQLNotConfigured
+	}
+
+	if len(key) > MySQLMaxLengthID {
+		return nil, ErrMySQLIDtooLong
+	}
+
+	counter.StartProcess()
+	defer counter.EndProcess()
+
+	where := []string{"k=?"}
+	args := []any{key}
+	if q.Action != "" {
+		where = append(where, "action=?")
+		args = append(args, q.Action)
+	}
+	if !q.From.IsZero() {
+		where = append(where, "time>=?")
+		args = append(args, q.From)
+	}
+	if !q.To.IsZero() {
+		where = append(where, "time<?")
+		args = append(args, q.To)
+	}
+
+	rows, err := m.db.Query("SELECT time, role, identity, ip, action, details FROM audit WHERE "+strings.Join(where, " AND ")+" ORDER BY id ASC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]registry.AuditEntry, 0)
+	for rows.Next() {
+		var e registry.AuditEntry
+		err = rows.Scan(&e.Time, &e.Role, &e.Identity, &e.IP, &e.Action, &e.Details)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, err
+}
+
+func (m *mysql) SaveDelivery(key, id string, d registry.Delivery) error {
+	if m.db == nil {
+		return ErrMySQLNotConfigured
+	}
+
+	if len(key) > MySQLMaxLengthID {
+		return ErrMySQLIDtooLong
+	}
+
+	if len(d.Plugin) > MySQLMaxLengthID {
+		return ErrMySQLIDtooLong
+	}
+
+	counter.StartProcess()
+	defer counter.EndProcess()
+
+	parsedId, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.db.Exec("REPLACE INTO delivery (announcement, k, plugin, state, recipients, sent, failed, error, updated) VALUES (?,?,?,?,?,?,?,?,?)", parsedId, key, d.Plugin, int(d.State), d.Recipients, d.Sent, d.Failed, d.Error, d.Updated)
+	return err
+}
+
+func (m *mysql) GetDeliveries(key, id string) ([]registry.Delivery, error) {
+	if m.db == nil {
+		return nil, ErrMySQLNotConfigured
+	}
+
+	if len(key) > MySQLMaxLengthID {
+		return nil, ErrMySQLIDtooLong
+	}
+
+	counter.StartProcess()
+	defer counter.EndProcess()
+
+	parsedId, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.Query("SELECT plugin, state, recipients, sent, failed, error, updated FROM delivery WHERE announcement=? AND k=?", parsedId, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]registry.Delivery, 0)
+	for rows.Next() {
+		var d registry.Delivery
+		var state int
+		err = rows.Scan(&d.Plugin, &state, &d.Recipients, &d.Sent, &d.Failed, &d.Error, &d.Updated)
+		if err != nil {
+			return nil, err
+		}
+		d.State = registry.DeliveryState(state)
+		result = append(result, d)
+	}
+	return result, err
+}
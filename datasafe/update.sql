@@ -0,0 +1,42 @@
+-- Statements to update an existing database to the current version of create.sql.
+-- Only run the statements added after your last update.
+
+-- Edit announcements
+ALTER TABLE announcementgo.announcement ADD COLUMN edited DATETIME NULL;
+CREATE TABLE announcementgo.revision (id BIGINT UNSIGNED AUTO_INCREMENT, announcement BIGINT UNSIGNED NOT NULL, header LONGTEXT NOT NULL, message LONGTEXT NOT NULL, time DATETIME NOT NULL, edited DATETIME NULL, PRIMARY KEY(id));
+CREATE INDEX announcement ON announcementgo.revision (announcement);
+
+-- Retract announcements
+ALTER TABLE announcementgo.announcement ADD COLUMN retracted DATETIME NULL;
+
+-- Announcement expiry
+ALTER TABLE announcementgo.announcement ADD COLUMN expires DATETIME NULL;
+
+-- Plugin selection
+ALTER TABLE announcementgo.announcement ADD COLUMN plugins LONGTEXT NULL;
+
+-- Attachments
+ALTER TABLE announcementgo.announcement ADD COLUMN attachments LONGTEXT NULL;
+CREATE TABLE announcementgo.attachment (id VARCHAR(64) CHARACTER SET latin1 NOT NULL, k VARCHAR(600) CHARACTER SET latin1 NOT NULL, data LONGBLOB NOT NULL, PRIMARY KEY(id));
+
+-- Categories
+ALTER TABLE announcementgo.announcement ADD COLUMN categories LONGTEXT NULL;
+
+-- Priority
+ALTER TABLE announcementgo.announcement ADD COLUMN priority INT NOT NULL DEFAULT 0;
+
+-- Variants
+ALTER TABLE announcementgo.announcement ADD COLUMN variants LONGTEXT NULL;
+
+-- Author
+ALTER TABLE announcementgo.announcement ADD COLUMN author VARCHAR(600) NOT NULL DEFAULT '';
+
+-- Audit log
+CREATE TABLE announcementgo.audit (id BIGINT UNSIGNED AUTO_INCREMENT, k VARCHAR(600) CHARACTER SET latin1 NOT NULL, time DATETIME NOT NULL, role VARCHAR(20) NOT NULL, identity LONGTEXT NOT NULL, ip LONGTEXT NOT NULL, action VARCHAR(100) NOT NULL, details LONGTEXT NOT NULL, PRIMARY KEY(id));
+CREATE INDEX audit_k ON announcementgo.audit (k);
+
+-- Delivery tracking
+CREATE TABLE announcementgo.delivery (announcement BIGINT UNSIGNED NOT NULL, k VARCHAR(600) CHARACTER SET latin1 NOT NULL, plugin VARCHAR(600) CHARACTER SET latin1 NOT NULL, state INT NOT NULL, recipients INT NOT NULL, sent INT NOT NULL, failed INT NOT NULL, error LONGTEXT NOT NULL, updated DATETIME NOT NULL, PRIMARY KEY(announcement, plugin));
+
+-- Complete revisions
+ALTER TABLE announcementgo.revision ADD COLUMN retracted DATETIME NULL, ADD COLUMN expires DATETIME NULL, ADD COLUMN priority INT NOT NULL DEFAULT 0, ADD COLUMN author VARCHAR(600) NOT NULL DEFAULT '', ADD COLUMN variants LONGTEXT NULL, ADD COLUMN categories LONGTEXT NULL, ADD COLUMN attachments LONGTEXT NULL, ADD COLUMN plugins LONGTEXT NULL;
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/Top-Ranger/announcementgo/registry"
+	"github.com/Top-Ranger/announcementgo/server"
+	"github.com/Top-Ranger/announcementgo/templates"
+	"github.com/Top-Ranger/announcementgo/translation"
+)
+
+// deliveryHandle shows the delivery status of a single announcement for all plugins.
+// Errors are only shown to persons who can see the errors of the key.
+func (a *announcement) deliveryHandle(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	loggedin, admin := server.GetLogin(a.Key, r)
+	if !loggedin {
+		rw.WriteHeader(http.StatusForbidden)
+		t := templates.TextTemplateStruct{Text: "403 Forbidden", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	an, err := registry.CurrentDataSafe.GetAnnouncement(a.Key, id)
+	if err != nil {
+		rw.WriteHeader(http.StatusNotFound)
+		t := templates.TextTemplateStruct{Text: "404 Not Found", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+
+	deliveries, err := registry.CurrentDataSafe.GetDeliveries(a.Key, id)
+	if err != nil {
+		log.Printf("announcement delivery (%s): %s", a.Key, err.Error())
+		rw.WriteHeader(http.StatusInternalServerError)
+		t := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+	a.sortDeliveries(deliveries)
+
+	td := templates.DeliveryTemplateStruct{
+		Key:              a.Key,
+		ShortDescription: a.ShortDescription,
+		Translation:      translation.GetDefaultTranslation(),
+		Announcement:     an,
+		Deliveries:       deliveries,
+		ShowErrors:       admin || a.UsersSeeErrors,
+	}
+	err = templates.DeliveryTemplate.Execute(rw, td)
+	if err != nil {
+		log.Printf("announcement delivery template (%s): %s", a.Key, err.Error())
+	}
+}
+
+// sortDeliveries sorts the deliveries in the order of the configured plugins.
+// Deliveries of plugins which are no longer configured are placed last.
+func (a *announcement) sortDeliveries(d []registry.Delivery) {
+	order := make(map[string]int, len(a.Plugins))
+	for i := range a.Plugins {
+		order[a.Plugins[i]] = i
+	}
+	position := func(plugin string) int {
+		p, ok := order[plugin]
+		if !ok {
+			return len(a.Plugins)
+		}
+		return p
+	}
+	sort.SliceStable(d, func(i, j int) bool {
+		return position(d[i].Plugin) < position(d[j].Plugin)
+	})
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/Top-Ranger/announcementgo/registry"
+)
+
+// expiringAnnouncement represents a published announcement which has not expired yet.
+type expiringAnnouncement struct {
+	ID      string
+	Expires time.Time
+}
+
+// watchExpiry sets the expiry time of the announcement with the given id.
+// A zero time removes the announcement from the list of expiring announcements.
+// Caller needs to lock.
+func (a *announcement) watchExpiry(id string, expires time.Time) {
+	expiring := make([]expiringAnnouncement, 0, len(a.expiring)+1)
+	for i := range a.expiring {
+		if a.expiring[i].ID != id {
+			expiring = append(expiring, a.expiring[i])
+		}
+	}
+	if !expires.IsZero() {
+		expiring = append(expiring, expiringAnnouncement{ID: id, Expires: expires})
+	}
+	a.expiring = expiring
+	a.saveInternal("expiring", &a.expiring)
+}
+
+// processExpiry returns the ids of all announcements which expired until now and removes them from the list of expiring announcements.
+// Caller needs to lock.
+func (a *announcement) processExpiry(now time.Time) []string {
+	due := make([]string, 0)
+	expiring := make([]expiringAnnouncement, 0, len(a.expiring))
+	for i := range a.expiring {
+		if now.Before(a.expiring[i].Expires) {
+			expiring = append(expiring, a.expiring[i])
+			continue
+		}
+		due = append(due, a.expiring[i].ID)
+	}
+	if len(due) != 0 {
+		a.expiring = expiring
+		a.saveInternal("expiring", &a.expiring)
+	}
+	return due
+}
+
+// expire informs all plugins that the announcement with the given id has expired.
+// The caller must not hold a.l.
+func (a *announcement) expire(id string) {
+	an, err := registry.CurrentDataSafe.GetAnnouncement(a.Key, id)
+	if err != nil {
+		log.Printf("announcement expire (%s): %s", a.Key, err.Error())
+		return
+	}
+	if !an.Retracted.IsZero() {
+		// Already removed from all plugins
+		return
+	}
+	plugins := a.targetPlugins(an)
+	for i := range plugins {
+		go plugins[i].ExpireAnnouncement(an, id)
+	}
+}
@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020 Marcus Soll
+// Copyright 2020,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -18,15 +18,18 @@ package helper
 import (
 	"bytes"
 	"fmt"
+	"html"
 	"html/template"
+	"strings"
 
 	"github.com/microcosm-cc/bluemonday"
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/extension"
-	"github.com/yuin/goldmark/renderer/html"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
 )
 
 var policy *bluemonday.Policy
+var strictPolicy = bluemonday.StrictPolicy()
 
 func init() {
 	policy = bluemonday.NewPolicy()
@@ -42,7 +45,7 @@ func init() {
 // Format returns a save html version of the Markdown input.
 func Format(b []byte) template.HTML {
 	buf := bytes.NewBuffer(make([]byte, 0, len(b)*2))
-	md := goldmark.New(goldmark.WithExtensions(extension.GFM), goldmark.WithRendererOptions(html.WithHardWraps()))
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM), goldmark.WithRendererOptions(gmhtml.WithHardWraps()))
 	err := md.Convert(b, buf)
 	if err != nil {
 		return template.HTML(policy.Sanitize(fmt.Sprintf("Error rendering markdown: %s", err.Error())))
@@ -50,3 +53,15 @@ func Format(b []byte) template.HTML {
 
 	return template.HTML(policy.SanitizeBytes(buf.Bytes()))
 }
+
+// Excerpt returns the plain text of the Markdown input shortened to at most max runes.
+// All whitespace is collapsed into single spaces.
+func Excerpt(b []byte, max int) string {
+	text := html.UnescapeString(strictPolicy.Sanitize(strings.ReplaceAll(string(Format(b)), "<", " <")))
+	text = strings.Join(strings.Fields(text), " ")
+	r := []rune(text)
+	if len(r) <= max {
+		return text
+	}
+	return strings.Join([]string{strings.TrimSpace(string(r[:max-1])), "…"}, "")
+}
@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Top-Ranger/announcementgo/registry"
+	"github.com/Top-Ranger/announcementgo/server"
+	"github.com/Top-Ranger/announcementgo/templates"
+	"github.com/Top-Ranger/announcementgo/translation"
+)
+
+// historyPageSize is the number of announcements shown on a single page of the history.
+const historyPageSize = 20
+
+// filterDateFormat is the format of the dates used for filtering.
+const filterDateFormat = "2006-01-02"
+
+// parseDateRange parses the dates of a filter. Empty dates result in zero times.
+// The returned end is the start of the day after to, so that the whole day is included.
+func parseDateRange(from, to string) (time.Time, time.Time, error) {
+	var start, end time.Time
+	var err error
+	if from != "" {
+		start, err = time.ParseInLocation(filterDateFormat, from, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	if to != "" {
+		end, err = time.ParseInLocation(filterDateFormat, to, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		end = end.AddDate(0, 0, 1)
+	}
+	return start, end, nil
+}
+
+// historyHandle shows a single page of the announcements matching the search given in the query of the request.
+func (a *announcement) historyHandle(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	loggedin, admin := server.GetLogin(a.Key, r)
+	if !loggedin {
+		rw.WriteHeader(http.StatusForbidden)
+		t := templates.TextTemplateStruct{Text: "403 Forbidden", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+
+	search := templates.HistorySearch{
+		Text: r.URL.Query().Get("q"),
+		From: r.URL.Query().Get("from"),
+		To:   r.URL.Query().Get("to"),
+	}
+	page := 1
+	var err error
+	if r.URL.Query().Get("page") != "" {
+		page, err = strconv.Atoi(r.URL.Query().Get("page"))
+		if err != nil || page < 1 {
+			rw.WriteHeader(http.StatusBadRequest)
+			t := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+			templates.TextTemplate.Execute(rw, t)
+			return
+		}
+	}
+
+	q := registry.AnnouncementQuery{
+		Text:   search.Text,
+		Offset: (page - 1) * historyPageSize,
+		Limit:  historyPageSize,
+	}
+	q.From, q.To, err = parseDateRange(search.From, search.To)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		t := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+
+	h, total, err := registry.CurrentDataSafe.SearchAnnouncements(a.Key, q)
+	if err != nil {
+		log.Printf("announcement history (%s): %s", a.Key, err.Error())
+		rw.WriteHeader(http.StatusInternalServerError)
+		t := templates.TextTemplateStruct{Text: "500 Internal Server Error", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+
+	td := templates.HistoryTemplateStruct{
+		Key:              a.Key,
+		ShortDescription: a.ShortDescription,
+		History:          h,
+		Translation:      translation.GetDefaultTranslation(),
+		Admin:            admin,
+		Languages:        variantLanguages(),
+		Plugins:          a.pluginNames,
+		Search:           search,
+		Total:            total,
+		Page:             page,
+		Pages:            (total + historyPageSize - 1) / historyPageSize,
+	}
+	if page > 1 {
+		td.PreviousPage = a.historyPageURL(search, page-1)
+	}
+	if page < td.Pages {
+		td.NextPage = a.historyPageURL(search, page+1)
+	}
+	err = templates.HistoryTemplate.Execute(rw, td)
+	if err != nil {
+		log.Printf("announcement history template (%s): %s", a.Key, err.Error())
+	}
+}
+
+// historyPageURL returns the URL of a page of the history with the given search.
+func (a *announcement) historyPageURL(search templates.HistorySearch, page int) string {
+	v := url.Values{}
+	if search.Text != "" {
+		v.Set("q", search.Text)
+	}
+	if search.From != "" {
+		v.Set("from", search.From)
+	}
+	if search.To != "" {
+		v.Set("to", search.To)
+	}
+	v.Set("page", strconv.Itoa(page))
+	return fmt.Sprintf("/%s/history.html?%s", a.Key, v.Encode())
+}
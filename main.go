@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020,2022 Marcus Soll
+// Copyright 2020,2022,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -16,6 +16,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -26,6 +27,7 @@ import (
 	"os/signal"
 	"runtime/debug"
 	"syscall"
+	"time"
 
 	"github.com/Top-Ranger/announcementgo/counter"
 	_ "github.com/Top-Ranger/announcementgo/datasafe"
@@ -40,6 +42,7 @@ import (
 type ConfigStruct struct {
 	Language                     string
 	Address                      string
+	ServerURL                    string
 	LogFailedLogin               bool
 	LoginMinutes                 int
 	PathConfig                   string
@@ -49,10 +52,24 @@ type ConfigStruct struct {
 	DataSafe                     string
 	DataSafeConfig               string
 	InsecureAllowCookiesOverHTTP bool
+	ShutdownTimeoutSeconds       int
+	SuperAdminPasswordMethod     string
+	SuperAdminPassword           []passwordEntry
 }
 
+// defaultShutdownTimeout is used if no shutdown timeout is configured.
+const defaultShutdownTimeout = 30 * time.Second
+
 var config ConfigStruct
 
+// shutdownTimeout returns the configured time plugins have to stop.
+func shutdownTimeout() time.Duration {
+	if config.ShutdownTimeoutSeconds > 0 {
+		return time.Duration(config.ShutdownTimeoutSeconds) * time.Second
+	}
+	return defaultShutdownTimeout
+}
+
 func loadConfig(path string) (ConfigStruct, error) {
 	log.Printf("main: Loading config (%s)", path)
 	b, err := os.ReadFile(path)
@@ -99,6 +116,7 @@ func main() {
 
 	configPath := flag.String("config", "./config.json", "Path to json config for AnnouncementGo!")
 	dumpAnnouncements := flag.String("dumpAnnouncements", "", "If set, all anouncements of the provided key will be dumped to stdout")
+	dumpSince := flag.String("dumpSince", "", "If set, only announcements after the provided id are dumped")
 	insertAnnouncements := flag.String("insertAnnouncements", "", "If set, announcements are read from stdin and directly inserted for the provided key (announcements will not be send)")
 	flag.Parse()
 
@@ -132,7 +150,7 @@ func main() {
 	registry.CurrentDataSafe = datasafe
 
 	if *dumpAnnouncements != "" {
-		a, err := datasafe.GetAllAnnouncements(*dumpAnnouncements)
+		a, err := datasafe.GetAnnouncementsSince(*dumpAnnouncements, *dumpSince)
 		if err != nil {
 			log.Println("Can not read announcements for dump:", err)
 			return
@@ -170,12 +188,19 @@ func main() {
 
 	server.ConfigCookieSecure(!c.InsecureAllowCookiesOverHTTP)
 
-	err = server.InitialiseServer(server.Config{Address: config.Address, PathDSGVO: config.PathDSGVO, PathImpressum: config.PathImpressum, CookieTimeMinute: config.LoginMinutes})
+	err = server.InitialiseServer(server.Config{Address: config.Address, ServerURL: config.ServerURL, PathDSGVO: config.PathDSGVO, PathImpressum: config.PathImpressum, CookieTimeMinute: config.LoginMinutes})
 	if err != nil {
 		log.Panicln(err)
 	}
 
-	err = LoadAnnouncements(config.PathConfig)
+	if dashboardEnabled() {
+		err = initialiseDashboard()
+		if err != nil {
+			log.Panicln(err)
+		}
+	}
+
+	err = LoadAnnouncements(context.Background(), config.PathConfig)
 	if err != nil {
 		log.Panicln(err)
 	}
@@ -183,13 +208,30 @@ func main() {
 	server.RunServer()
 
 	s := make(chan os.Signal, 1)
-	signal.Notify(s, os.Interrupt, syscall.SIGTERM)
+	signal.Notify(s, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 
 	log.Println("main: waiting")
 
-	for range s {
+	for sig := range s {
+		if sig == syscall.SIGHUP {
+			reloadAnnouncements()
+			continue
+		}
+
+		timeout := shutdownTimeout()
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		log.Printf("main: shutting down (timeout %s)", timeout)
 		server.StopServer()
-		counter.WaitProcesses()
+		notStopped := StopAnnouncements(ctx)
+		for i := range notStopped {
+			log.Printf("main: plugin %s did not stop in time", notStopped[i])
+		}
+		err = counter.WaitProcessesContext(ctx)
+		if err != nil {
+			log.Println("main: not all processes finished in time:", err)
+		}
 		return
 	}
 }
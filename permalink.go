@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Top-Ranger/announcementgo/helper"
+	"github.com/Top-Ranger/announcementgo/registry"
+	"github.com/Top-Ranger/announcementgo/server"
+	"github.com/Top-Ranger/announcementgo/templates"
+	"github.com/Top-Ranger/announcementgo/translation"
+)
+
+// permalinkDescriptionLength is the maximum length of the description used in the OpenGraph metadata.
+const permalinkDescriptionLength = 200
+
+// permalinkHandle shows a single announcement under /<key>/a/<id>.
+// Logged in users can see all announcements, everyone else only announcements in the public archive.
+// Retracted and expired announcements are gone for everyone else.
+func (a *announcement) permalinkHandle(rw http.ResponseWriter, r *http.Request) {
+	loggedin, _ := server.GetLogin(a.Key, r)
+	if !loggedin && !a.PublicArchive {
+		rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+		rw.WriteHeader(http.StatusForbidden)
+		t := templates.TextTemplateStruct{Text: "403 Forbidden", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+
+	id, err := url.PathUnescape(strings.TrimPrefix(r.URL.Path, fmt.Sprintf("/%s/a/", a.Key)))
+	if err != nil || id == "" || strings.Contains(id, "/") {
+		rw.WriteHeader(http.StatusNotFound)
+		t := templates.TextTemplateStruct{Text: "404 Not Found", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+
+	an, err := registry.CurrentDataSafe.GetAnnouncement(a.Key, id)
+	if err != nil {
+		rw.WriteHeader(http.StatusNotFound)
+		t := templates.TextTemplateStruct{Text: "404 Not Found", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+
+	if !loggedin && (!an.Retracted.IsZero() || an.Expired()) {
+		rw.WriteHeader(http.StatusGone)
+		t := templates.TextTemplateStruct{Text: "410 Gone", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+
+	td := templates.PermalinkTemplateStruct{
+		Key:              a.Key,
+		ShortDescription: a.ShortDescription,
+		Translation:      translation.GetDefaultTranslation(),
+		Announcement:     an,
+		URL:              strings.Join([]string{server.ServerURL(), an.Path(a.Key)}, ""),
+		Description:      helper.Excerpt([]byte(an.Message), permalinkDescriptionLength),
+		Robots:           a.Robots,
+		LoggedIn:         loggedin,
+		PublicArchive:    a.PublicArchive,
+	}
+
+	// The same URL serves additional content to logged in users
+	rw.Header().Set("Vary", "Cookie")
+	if loggedin {
+		rw.Header().Set("Cache-Control", "private, no-cache")
+	} else {
+		rw.Header().Set("Cache-Control", "public, max-age=300")
+	}
+	err = templates.PermalinkTemplate.Execute(rw, td)
+	if err != nil {
+		log.Printf("announcement permalink template (%s): %s", a.Key, err.Error())
+	}
+}
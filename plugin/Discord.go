@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020,2021 Marcus Soll
+// Copyright 2020,2021,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -17,6 +17,7 @@ package plugin
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
 	"fmt"
 	"html/template"
@@ -26,6 +27,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/Top-Ranger/announcementgo/counter"
 	"github.com/Top-Ranger/announcementgo/helper"
@@ -69,7 +71,11 @@ func discordFactory(key, shortDescription string, errorChannel chan string) (reg
 			}
 		}
 	}
+	if d.SentTime == nil {
+		d.SentTime = make(map[string]time.Time)
+	}
 	d.l = new(sync.Mutex)
+	d.workers = new(lifecycle)
 	d.key = key
 	d.e = errorChannel
 
@@ -87,15 +93,19 @@ const discordConfig = `
 {{if .URL}}
 <p>{{.URL}}</p>
 <p>Administrators of servers can mention the bot on a channel to tell the bot to use that channel for announcements.</p>
+<p>Administrators of servers can mention the bot together with a role to tell the bot to mention that role for urgent announcements instead of everyone.</p>
 {{end}}
 <p>{{.UserNumber}} users</p>
 <form method="POST">
 	<input type="hidden" name="target" value="Discord">
 	<p><input id="Discord_token" type="text" name="token" value="{{.Token}}" placeholder="token"> <label for="Discord_token">Discord Bot API token</label></p>
+	<p><input id="Discord_deleteexpired" type="checkbox" name="deleteexpired" {{if .DeleteExpired}}checked{{end}}> <label for="Discord_deleteexpired">delete messages of expired announcements</label></p>
 	<p><input type="submit" value="Update"></p>
 </form>
 `
 
+const discordLimit = 1500 // discord character limit
+
 var discordConfigTemplate *template.Template
 
 type discordConfigTemplateStruct struct {
@@ -104,16 +114,32 @@ type discordConfigTemplateStruct struct {
 	Token               string
 	UserNumber          string
 	URL                 string
+	DeleteExpired       bool
+}
+
+// discordSentMessage represents a message sent to a channel.
+// File is true if the announcement was sent as a file, Attachment is true if the message contains an attachment of the announcement.
+type discordSentMessage struct {
+	ChannelID  string
+	MessageID  string
+	File       bool
+	Attachment bool
 }
 
 type discord struct {
-	Token       string
-	TokenHidden bool
-	Channels    map[string]string
+	Token         string
+	TokenHidden   bool
+	DeleteExpired bool
+	Channels      map[string]string
+	UrgentRoles   map[string]string
+	Sent          map[string][]discordSentMessage
+	SentTime      map[string]time.Time
 
 	bot          *discordgo.Session
 	currentToken string
+	running      bool // bot is only connected between Start and Stop
 	l            *sync.Mutex
+	workers      *lifecycle
 	e            chan string
 	key          string
 }
@@ -130,7 +156,7 @@ func (d *discord) update() error {
 		}
 	}
 
-	if d.bot == nil && d.Token != "" {
+	if d.bot == nil && d.Token != "" && d.running {
 		var err error
 		d.bot, err = discordgo.New("Bot " + d.Token)
 		if err != nil {
@@ -200,10 +226,20 @@ func (d *discord) update() error {
 
 				d.l.Lock()
 				defer d.l.Unlock()
-				if d.Channels == nil {
-					d.Channels = make(map[string]string)
+				answer := translation.GetDefaultTranslation().BotSendOnThisChannel
+				if len(m.Message.MentionRoles) != 0 {
+					// Mentioning a role sets the role used for urgent announcements
+					if d.UrgentRoles == nil {
+						d.UrgentRoles = make(map[string]string)
+					}
+					d.UrgentRoles[m.Message.GuildID] = m.Message.MentionRoles[0]
+					answer = translation.GetDefaultTranslation().BotUrgentRole
+				} else {
+					if d.Channels == nil {
+						d.Channels = make(map[string]string)
+					}
+					d.Channels[m.Message.GuildID] = m.Message.ChannelID
 				}
-				d.Channels[m.Message.GuildID] = m.Message.ChannelID
 				err = d.update()
 				if err != nil {
 					em := fmt.Sprintln("discord:", err)
@@ -212,7 +248,7 @@ func (d *discord) update() error {
 					return
 				}
 
-				_, err = d.bot.ChannelMessageSend(m.ChannelID, translation.GetDefaultTranslation().BotSendOnThisChannel)
+				_, err = d.bot.ChannelMessageSend(m.ChannelID, answer)
 				if err != nil {
 					em := fmt.Sprintln("discord:", err)
 					log.Println(em)
@@ -258,6 +294,47 @@ func (d *discord) update() error {
 	return nil
 }
 
+func (d *discord) Start(ctx context.Context) error {
+	d.l.Lock()
+	d.running = true
+	err := d.update()
+	d.l.Unlock()
+	if err != nil {
+		return err
+	}
+	d.workers.start(ctx, d.connectionWorker)
+	return nil
+}
+
+func (d *discord) Stop(ctx context.Context) error {
+	return d.workers.stop(ctx, func() {
+		d.l.Lock()
+		defer d.l.Unlock()
+		d.disconnect()
+	})
+}
+
+// connectionWorker closes the connection once ctx is done. The connection itself is opened by update.
+func (d *discord) connectionWorker(ctx context.Context) {
+	<-ctx.Done()
+	d.l.Lock()
+	defer d.l.Unlock()
+	d.disconnect()
+}
+
+// disconnect closes the connection and prevents the bot from connecting again.
+func (d *discord) disconnect() {
+	// caller has to lock
+	d.running = false
+	if d.bot != nil {
+		err := d.bot.Close()
+		if err != nil {
+			log.Printf("discord (%s): %s", d.key, err.Error())
+		}
+		d.bot = nil
+	}
+}
+
 func (d *discord) GetConfig() template.HTML {
 	counter.StartProcess()
 	defer counter.EndProcess()
@@ -265,10 +342,11 @@ func (d *discord) GetConfig() template.HTML {
 	defer d.l.Unlock()
 
 	td := discordConfigTemplateStruct{
-		Valid:      d.bot != nil,
-		Token:      d.Token,
-		UserNumber: "[not available]",
-		URL:        "Create bot: https://discord.com/developers/applications",
+		Valid:         d.bot != nil,
+		Token:         d.Token,
+		UserNumber:    "[not available]",
+		URL:           "Create bot: https://discord.com/developers/applications",
+		DeleteExpired: d.DeleteExpired,
 	}
 
 	if d.bot != nil {
@@ -319,6 +397,7 @@ func (d *discord) ProcessConfigChange(r *http.Request) error {
 	defer d.l.Unlock()
 
 	d.Token = r.Form.Get("token")
+	d.DeleteExpired = r.Form.Get("deleteexpired") != ""
 
 	err = d.update()
 	if err != nil {
@@ -331,6 +410,44 @@ func (d *discord) ProcessConfigChange(r *http.Request) error {
 	return nil
 }
 
+func (d *discord) Settings() []registry.Setting {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	d.l.Lock()
+	defer d.l.Unlock()
+	return []registry.Setting{
+		{Name: "token", Value: d.Token, Secret: true},
+		{Name: "deleteexpired", Value: strconv.FormatBool(d.DeleteExpired)},
+	}
+}
+
+func (d *discord) Preview(a registry.Announcement) template.HTML {
+	counter.StartProcess()
+	defer counter.EndProcess()
+
+	message := withPermalink(d.key, strings.Join([]string{a.Header, a.Message}, "\n\n"), a)
+	mention := ""
+	if a.Priority == registry.PriorityUrgent {
+		// The mentioned role depends on the server
+		mention = "@everyone\n\n"
+	}
+	title := "Message"
+	if a.Priority == registry.PriorityLow {
+		title = "Message (notifications suppressed)"
+	}
+	parts := make([]previewPart, 0, 3)
+	if len(mention)+len(message) > discordLimit {
+		parts = append(parts, previewPart{Title: title, Text: mention})
+		parts = append(parts, previewPart{Title: fmt.Sprintf("File %s.txt (message is longer than %d characters)", d.key, discordLimit), Text: message})
+	} else {
+		parts = append(parts, previewPart{Title: title, Text: strings.Join([]string{mention, message}, "")})
+	}
+	if p, ok := attachmentPreview(a); ok {
+		parts = append(parts, p)
+	}
+	return renderPreview(parts)
+}
+
 func (d *discord) NewAnnouncement(a registry.Announcement, id string) {
 	counter.StartProcess()
 	defer counter.EndProcess()
@@ -339,25 +456,62 @@ func (d *discord) NewAnnouncement(a registry.Announcement, id string) {
 
 	if d.bot == nil {
 		// no bot configurated - jump out
+		registry.ReportDeliveryFailed(d.key, id, "Discord", errNotConfigured)
 		return
 	}
 
 	// used in send
-	message := strings.Join([]string{a.Header, a.Message}, "\n\n")
+	message := withPermalink(d.key, strings.Join([]string{a.Header, a.Message}, "\n\n"), a)
+	attachments := newAttachmentCache(d.key)
 
 	send := func(channelID string) error {
 		// caller has to lock
-		if len(message) > 1500 { // discord character limit
-			// We probably need to send a file
-			buf := bytes.NewBufferString(message)
-			_, err := d.bot.ChannelFileSend(channelID, strings.Join([]string{d.key, "txt"}, "."), buf)
+		sent, err := d.send(channelID, message, a.Priority)
+		if err != nil {
 			return err
 		}
-		// We can send the string
-		_, err := d.bot.ChannelMessageSend(channelID, message)
-		return err
+		if d.Sent == nil {
+			d.Sent = make(map[string][]discordSentMessage)
+		}
+		if _, ok := d.SentTime[id]; !ok {
+			d.SentTime[id] = time.Now()
+		}
+		d.Sent[id] = append(d.Sent[id], sent)
+
+		for i := range a.Attachments {
+			b, err := attachments.get(a.Attachments[i])
+			if err != nil {
+				return err
+			}
+			m, err := d.bot.ChannelFileSend(channelID, a.Attachments[i].Name, bytes.NewReader(b))
+			if err != nil {
+				return err
+			}
+			d.Sent[id] = append(d.Sent[id], discordSentMessage{ChannelID: m.ChannelID, MessageID: m.ID, Attachment: true})
+		}
+		return nil
 	}
 
+	defer func() {
+		pruneSent(d.Sent, d.SentTime, time.Now())
+		err := d.update()
+		if err != nil {
+			em := fmt.Sprintln("discord:", err)
+			log.Println(em)
+			d.e <- em
+		}
+	}()
+
+	// Every guild counts as a recipient
+	sent, failed := 0, 0
+	var lastErr, guildErr error
+	defer func() {
+		registry.ReportDeliveryProgress(d.key, id, "Discord", sent, failed, lastErr)
+		if guildErr != nil {
+			registry.ReportDeliveryFailed(d.key, id, "Discord", guildErr)
+		}
+	}()
+
 	startid := ""
 	loop := true
 
@@ -367,6 +521,7 @@ func (d *discord) NewAnnouncement(a registry.Announcement, id string) {
 			em := fmt.Sprintln("discord:", err)
 			log.Println(em)
 			d.e <- em
+			guildErr = err
 			break
 		}
 
@@ -379,6 +534,8 @@ func (d *discord) NewAnnouncement(a registry.Announcement, id string) {
 				em := fmt.Sprintln("discord:", err)
 				log.Println(em)
 				d.e <- em
+				failed++
+				lastErr = err
 				continue
 			}
 
@@ -395,6 +552,7 @@ func (d *discord) NewAnnouncement(a registry.Announcement, id string) {
 							em := fmt.Sprintln("discord:", err)
 							log.Println(em)
 							d.e <- em
+							lastErr = err
 							continue
 						}
 						messageSent = true
@@ -412,6 +570,7 @@ func (d *discord) NewAnnouncement(a registry.Announcement, id string) {
 							em := fmt.Sprintln("discord:", err)
 							log.Println(em)
 							d.e <- em
+							lastErr = err
 							continue
 						}
 						messageSent = true
@@ -429,12 +588,170 @@ func (d *discord) NewAnnouncement(a registry.Announcement, id string) {
 							em := fmt.Sprintln("discord:", err)
 							log.Println(em)
 							d.e <- em
+							lastErr = err
+							break
 						}
 						messageSent = true
 						break
 					}
 				}
 			}
+
+			// Guilds without a suitable channel are no recipients
+			if messageSent {
+				sent++
+			} else if err != nil {
+				failed++
+			}
+		}
+	}
+}
+
+func (d *discord) UpdateAnnouncement(a registry.Announcement, id string) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	d.l.Lock()
+	defer d.l.Unlock()
+
+	if d.bot == nil {
+		// no bot configurated - jump out
+		return
+	}
+
+	message := withPermalink(d.key, strings.Join([]string{a.Header, a.Message}, "\n\n"), a)
+
+	for i, sent := range d.Sent[id] {
+		if sent.Attachment {
+			// Attachments don't change
+			continue
+		}
+		content := strings.Join([]string{d.mention(sent.ChannelID, a.Priority), message}, "")
+		if !sent.File && len(content) <= discordLimit {
+			_, err := d.bot.ChannelMessageEdit(sent.ChannelID, sent.MessageID, content)
+			if err != nil {
+				em := fmt.Sprintln("discord:", err)
+				log.Println(em)
+				d.e <- em
+			}
+			continue
+		}
+
+		// Files can not be edited, replace message instead
+		err := d.bot.ChannelMessageDelete(sent.ChannelID, sent.MessageID)
+		if err != nil {
+			em := fmt.Sprintln("discord:", err)
+			log.Println(em)
+			d.e <- em
+		}
+		newSent, err := d.send(sent.ChannelID, message, a.Priority)
+		if err != nil {
+			em := fmt.Sprintln("discord:", err)
+			log.Println(em)
+			d.e <- em
+			continue
+		}
+		d.Sent[id][i] = newSent
+	}
+
+	err := d.update()
+	if err != nil {
+		em := fmt.Sprintln("discord:", err)
+		log.Println(em)
+		d.e <- em
+	}
+}
+
+func (d *discord) RetractAnnouncement(a registry.Announcement, id string) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	d.l.Lock()
+	defer d.l.Unlock()
+
+	d.remove(id)
+}
+
+func (d *discord) ExpireAnnouncement(a registry.Announcement, id string) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	d.l.Lock()
+	defer d.l.Unlock()
+
+	if !d.DeleteExpired {
+		// Messages of expired announcements are no longer edited
+		delete(d.Sent, id)
+		delete(d.SentTime, id)
+		err := d.update()
+		if err != nil {
+			em := fmt.Sprintln("discord:", err)
+			log.Println(em)
+			d.e <- em
+		}
+		return
+	}
+
+	d.remove(id)
+}
+
+func (d *discord) remove(id string) {
+	// caller has to lock
+	if d.bot == nil {
+		// no bot configurated - jump out
+		return
+	}
+
+	for _, sent := range d.Sent[id] {
+		err := d.bot.ChannelMessageDelete(sent.ChannelID, sent.MessageID)
+		if err != nil {
+			em := fmt.Sprintln("discord:", err)
+			log.Println(em)
+			d.e <- em
 		}
 	}
+	delete(d.Sent, id)
+	delete(d.SentTime, id)
+
+	err := d.update()
+	if err != nil {
+		em := fmt.Sprintln("discord:", err)
+		log.Println(em)
+		d.e <- em
+	}
+}
+
+func (d *discord) send(channelID, message string, priority registry.Priority) (discordSentMessage, error) {
+	// caller has to lock
+	ms := &discordgo.MessageSend{}
+	if priority == registry.PriorityLow {
+		ms.Flags = discordgo.MessageFlagsSuppressNotifications
+	}
+	mention := d.mention(channelID, priority)
+	file := false
+	if len(mention)+len(message) > discordLimit {
+		// We probably need to send a file
+		ms.Content = mention
+		ms.Files = []*discordgo.File{{Name: strings.Join([]string{d.key, "txt"}, "."), Reader: bytes.NewBufferString(message)}}
+		file = true
+	} else {
+		// We can send the string
+		ms.Content = strings.Join([]string{mention, message}, "")
+	}
+	m, err := d.bot.ChannelMessageSendComplex(channelID, ms)
+	if err != nil {
+		return discordSentMessage{}, err
+	}
+	return discordSentMessage{ChannelID: m.ChannelID, MessageID: m.ID, File: file}, nil
+}
+
+// mention returns the mention which should be put in front of an announcement with the given priority.
+// Urgent announcements mention the role set for the server or everyone.
+func (d *discord) mention(channelID string, priority registry.Priority) string {
+	// caller has to lock
+	if priority != registry.PriorityUrgent {
+		return ""
+	}
+	c, err := d.bot.Channel(channelID)
+	if err == nil && d.UrgentRoles[c.GuildID] != "" {
+		return fmt.Sprintf("<@&%s>\n\n", d.UrgentRoles[c.GuildID])
+	}
+	return "@everyone\n\n"
 }
@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020 Marcus Soll
+// Copyright 2020,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -17,12 +17,14 @@ package plugin
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -30,6 +32,8 @@ import (
 	"github.com/Top-Ranger/announcementgo/helper"
 	"github.com/Top-Ranger/announcementgo/registry"
 	"github.com/Top-Ranger/announcementgo/server"
+	"github.com/Top-Ranger/announcementgo/templates"
+	"github.com/Top-Ranger/announcementgo/translation"
 	"github.com/gorilla/feeds"
 )
 
@@ -58,9 +62,30 @@ func rssFactory(key, shortDescription string, errorChannel chan string) (registr
 	r.key, r.shortDescription = key, shortDescription
 
 	server.AddHandle(r.key, "RSS/feed.rss", func(rw http.ResponseWriter, req *http.Request) {
+		category := req.URL.Query().Get("category")
+		language := req.URL.Query().Get("language")
+		r.l.Lock()
+		_, ok := r.Caches[rssCacheKey(language, category)]
+		r.l.Unlock()
+		if category != "" && !ok && len(registry.FilterCategories(r.key, []string{category})) != 0 {
+			// Category was added after the last update
+			r.update()
+		}
+
 		r.l.Lock()
 		defer r.l.Unlock()
-		rw.Write(r.Cache)
+		if category == "" && language == "" {
+			rw.Write(r.Cache)
+			return
+		}
+		c, ok := r.Caches[rssCacheKey(language, category)]
+		if !ok {
+			rw.WriteHeader(http.StatusNotFound)
+			t := templates.TextTemplateStruct{Text: "404 Not Found", Translation: translation.GetDefaultTranslation()}
+			templates.TextTemplate.Execute(rw, t)
+			return
+		}
+		rw.Write(c)
 	})
 
 	r.e = errorChannel
@@ -71,11 +96,23 @@ func rssFactory(key, shortDescription string, errorChannel chan string) (registr
 type rss struct {
 	NumberShown int
 	Cache       []byte
+	Caches      map[string][]byte
 	Link        string
 
 	l                     *sync.Mutex
 	key, shortDescription string
 	e                     chan string
+	pending               []string // IDs of new announcements which are not yet part of the feeds
+}
+
+func (r *rss) Start(ctx context.Context) error {
+	// RSS has no background work
+	return nil
+}
+
+func (r *rss) Stop(ctx context.Context) error {
+	// The feeds are saved on every change
+	return nil
 }
 
 func (r *rss) GetConfig() template.HTML {
@@ -86,6 +123,7 @@ func (r *rss) GetConfig() template.HTML {
 	<h1>RSS</h1>
 	%s
 	<p id="RSS_path"></p>
+	<p>Add ?language=&lt;language code&gt; and/or ?category=&lt;category&gt; for filtered feeds.</p>
 	<form method="POST">
 	<input type="hidden" name="target" value="RSS">
 	<p><input type="number" id="RSS_items" name="items" min="0" step="1" value="%d" required> <label for="RSS_items">number items</label></p>
@@ -136,7 +174,55 @@ func (r *rss) ProcessConfigChange(req *http.Request) error {
 	return nil
 }
 
+func (r *rss) Settings() []registry.Setting {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	r.l.Lock()
+	defer r.l.Unlock()
+	return []registry.Setting{
+		{Name: "items", Value: strconv.Itoa(r.NumberShown)},
+		{Name: "link", Value: r.Link},
+	}
+}
+
+func (r *rss) Preview(a registry.Announcement) template.HTML {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	item := r.item(a)
+	// The description only contains sanitised HTML and escaped links
+	return renderPreview([]previewPart{
+		{Title: "Title", Text: item.Title},
+		{Title: "Description", HTML: template.HTML(item.Description)},
+	})
+}
+
 func (r *rss) NewAnnouncement(a registry.Announcement, id string) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	// a is not used, get the announcements directly from data safe
+	if id != "" {
+		r.l.Lock()
+		r.pending = append(r.pending, id)
+		r.l.Unlock()
+	}
+	r.update()
+}
+
+func (r *rss) UpdateAnnouncement(a registry.Announcement, id string) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	// a and id are not used, get the announcements directly from data safe
+	r.update()
+}
+
+func (r *rss) RetractAnnouncement(a registry.Announcement, id string) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	// a and id are not used, get the announcements directly from data safe
+	r.update()
+}
+
+func (r *rss) ExpireAnnouncement(a registry.Announcement, id string) {
 	counter.StartProcess()
 	defer counter.EndProcess()
 	// a and id are not used, get the announcements directly from data safe
@@ -147,7 +233,7 @@ func (r *rss) update() {
 	counter.StartProcess()
 	defer counter.EndProcess()
 
-	an, err := registry.CurrentDataSafe.GetAllAnnouncements(r.key)
+	an, err := r.announcements()
 
 	if err != nil {
 		em := fmt.Sprintln("rss:", err)
@@ -162,6 +248,123 @@ func (r *rss) update() {
 
 	r.l.Lock()
 	defer r.l.Unlock()
+
+	// The delivery is finished once the announcement is part of the feeds
+	defer func(pending []string, err error) {
+		for i := range pending {
+			if err != nil {
+				registry.ReportDeliveryRetrying(r.key, pending[i], "RSS", err)
+				continue
+			}
+			registry.ReportDeliveryProgress(r.key, pending[i], "RSS", 1, 0, nil)
+		}
+	}(r.pending, err)
+	if err == nil {
+		r.pending = nil
+	}
+
+	r.Cache = r.feed(an)
+
+	// Feeds are created for all combinations of language and category, empty strings mean no selection.
+	// Announcements without categories are part of all category feeds.
+	languages := append([]string{""}, translation.GetLanguages()...)
+	categories := append([]string{""}, registry.GetCategories(r.key)...)
+	r.Caches = make(map[string][]byte, len(languages)*len(categories))
+	for l := range languages {
+		for c := range categories {
+			filtered := make([]registry.Announcement, 0, len(an))
+			for i := range an {
+				if categories[c] == "" || an[i].MatchesCategories(categories[c:c+1]) {
+					filtered = append(filtered, an[i].Localised(languages[l]))
+				}
+			}
+			r.Caches[rssCacheKey(languages[l], categories[c])] = r.feed(filtered)
+		}
+	}
+
+	var config bytes.Buffer
+	enc := gob.NewEncoder(&config)
+	err = enc.Encode(r)
+	if err != nil {
+		em := fmt.Sprintln("rss:", err)
+		log.Println(em)
+		r.e <- em
+	}
+	err = registry.CurrentDataSafe.SetConfig(r.key, "RSS", config.Bytes())
+	if err != nil {
+		em := fmt.Sprintln("rss:", err)
+		log.Println(em)
+		r.e <- em
+	}
+}
+
+// announcements returns all announcements which might be shown in the feeds (oldest first).
+// Only the newest announcements needed to fill all feeds are read from the data safe.
+func (r *rss) announcements() ([]registry.Announcement, error) {
+	r.l.Lock()
+	n := r.NumberShown
+	r.l.Unlock()
+
+	if n == 0 {
+		an, err := registry.CurrentDataSafe.GetAllAnnouncements(r.key)
+		return rssShown(an), err
+	}
+
+	total, err := registry.CurrentDataSafe.CountAnnouncements(r.key)
+	if err != nil {
+		return nil, err
+	}
+	categories := append([]string{""}, registry.GetCategories(r.key)...)
+
+	// Some announcements might not be shown, so the number of read announcements is increased until all feeds are filled
+	for window := n; ; window *= 2 {
+		an, err := registry.CurrentDataSafe.GetLastAnnouncements(r.key, window)
+		if err != nil {
+			return nil, err
+		}
+		shown := rssShown(an)
+		if window >= total || rssFilled(shown, categories, n) {
+			return shown, nil
+		}
+	}
+}
+
+// rssShown returns all announcements of an which are shown in the feeds.
+// Retracted and expired announcements as well as announcements not sent to RSS are not shown.
+func rssShown(an []registry.Announcement) []registry.Announcement {
+	shown := make([]registry.Announcement, 0, len(an))
+	for i := range an {
+		if an[i].Retracted.IsZero() && !an[i].Expired() && an[i].SentTo("RSS") {
+			shown = append(shown, an[i])
+		}
+	}
+	return shown
+}
+
+// rssFilled returns whether the feeds of all categories contain at least n announcements.
+func rssFilled(an []registry.Announcement, categories []string, n int) bool {
+	for c := range categories {
+		count := 0
+		for i := range an {
+			if categories[c] == "" || an[i].MatchesCategories(categories[c:c+1]) {
+				count++
+			}
+		}
+		if count < n {
+			return false
+		}
+	}
+	return true
+}
+
+// rssCacheKey returns the key of the feed for the language and category in rss.Caches.
+func rssCacheKey(language, category string) string {
+	return strings.Join([]string{language, category}, "##")
+}
+
+// feed returns the RSS feed containing the newest announcements of an.
+// Caller has to lock.
+func (r *rss) feed(an []registry.Announcement) []byte {
 	if r.NumberShown != 0 {
 		start := len(an) - r.NumberShown
 		if start < 0 {
@@ -179,33 +382,43 @@ func (r *rss) update() {
 	}
 
 	for i := len(an) - 1; i >= 0; i-- {
-		m := helper.Format([]byte(an[i].Message))
-		feed.Items = append(feed.Items, &feeds.Item{
-			Title:       an[i].Header,
-			Description: string(m),
-			Link:        &feeds.Link{},
-			Created:     an[i].Time,
-		})
+		feed.Items = append(feed.Items, r.item(an[i]))
 	}
 
 	data, err := feed.ToRss()
 	if err != nil {
 		log.Println("feed:", err)
 	}
-	r.Cache = []byte(data)
+	return []byte(data)
+}
 
-	var config bytes.Buffer
-	enc := gob.NewEncoder(&config)
-	err = enc.Encode(r)
-	if err != nil {
-		em := fmt.Sprintln("rss:", err)
-		log.Println(em)
-		r.e <- em
+// item returns the feed item of the announcement.
+func (r *rss) item(a registry.Announcement) *feeds.Item {
+	m := helper.Format([]byte(a.Message))
+	item := &feeds.Item{
+		Title:       a.Header,
+		Description: string(m),
+		Link:        &feeds.Link{},
+		Created:     a.Time,
 	}
-	err = registry.CurrentDataSafe.SetConfig(r.key, "RSS", config.Bytes())
-	if err != nil {
-		em := fmt.Sprintln("rss:", err)
-		log.Println(em)
-		r.e <- em
+	if a.ID != "" && server.ServerURL() != "" {
+		u := permalinkURL(r.key, a)
+		item.Link.Href = u
+		item.Id = u
+		// The link only leads to the announcement for readers without login if the key is public
+		item.IsPermaLink = strconv.FormatBool(registry.IsPublic(r.key))
+	}
+	if !registry.IsPublic(r.key) {
+		// Attachments can only be downloaded with login
+		return item
+	}
+	// RSS only supports a single enclosure, so all attachments are linked in the description
+	for j := range a.Attachments {
+		u := strings.Join([]string{server.ServerURL(), a.Attachments[j].Path(r.key)}, "")
+		if j == 0 {
+			item.Enclosure = &feeds.Enclosure{Url: u, Length: strconv.Itoa(a.Attachments[j].Size), Type: a.Attachments[j].ContentType}
+		}
+		item.Description = fmt.Sprintf("%s<p><a href=\"%s\">%s</a></p>", item.Description, template.HTMLEscapeString(u), template.HTMLEscapeString(a.Attachments[j].Name))
 	}
+	return item
 }
@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020,2021,2022 Marcus Soll
+// Copyright 2020,2021,2022,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -17,9 +17,11 @@ package plugin
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/base64"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
@@ -91,13 +93,15 @@ func registerMailFactory(key, shortDescription string, errorChannel chan string)
 			}
 		}
 	}
+	if r.SentTime == nil {
+		r.SentTime = make(map[string]time.Time)
+	}
 	r.l = new(sync.Mutex)
+	r.workers = new(lifecycle)
 	r.key = key
 	r.description = shortDescription
 	r.e = errorChannel
 
-	go r.sendWorker()
-
 	server.AddHandle(key, "RegisterMail/subscribe.html", func(rw http.ResponseWriter, req *http.Request) {
 		counter.StartProcess()
 		r.l.Lock()
@@ -134,6 +138,8 @@ func registerMailFactory(key, shortDescription string, errorChannel chan string)
 				CaptchaID:        id,
 				Captcha:          c,
 				RegisterPassword: r.RegisterPassword != "",
+				Categories:       registry.GetCategories(r.key),
+				Languages:        translation.GetLanguages(),
 				Translation:      tl,
 			}
 			var buf bytes.Buffer
@@ -225,6 +231,14 @@ func registerMailFactory(key, shortDescription string, errorChannel chan string)
 				}
 			}
 
+			language := ""
+			for _, l := range translation.GetLanguages() {
+				if l == req.Form.Get("language") {
+					language = l
+					break
+				}
+			}
+
 			hash, salt, err := helper.Hash([]byte(m.Address))
 			if err != nil {
 				log.Printf("RegisterMail (%s): %s", r.key, err.Error())
@@ -237,9 +251,11 @@ func registerMailFactory(key, shortDescription string, errorChannel chan string)
 			saltString := base64.StdEncoding.EncodeToString(salt)
 
 			r.ToData = append(r.ToData, registerMailData{
-				Data: base64.StdEncoding.EncodeToString(hash),
-				Salt: saltString,
-				Hash: true,
+				Data:       base64.StdEncoding.EncodeToString(hash),
+				Salt:       saltString,
+				Hash:       true,
+				Categories: registry.FilterCategories(r.key, req.Form["category"]),
+				Language:   language,
 			})
 
 			err = r.save()
@@ -676,6 +692,12 @@ const registerMailRegisterSite = `
    {{if .RegisterPassword}}
    <p>{{.Translation.Password}} <br> <input type="password" name="rp" placeholder="{{.Translation.Password}}" required></p>
    {{end}}
+   {{if gt (len .Languages) 1}}
+   <p>{{.Translation.RegisterMailLanguage}}: <br> <select name="language">{{range $i, $e := .Languages}}<option value="{{$e}}"{{if eq $e $.Translation.Language}} selected{{end}}>{{$e}}</option>{{end}}</select></p>
+   {{end}}
+   {{if .Categories}}
+   <p>{{.Translation.RegisterMailCategories}}: <br> {{range $i, $e := .Categories}}<input type="checkbox" id="category_{{$i}}" name="category" value="{{$e}}"> <label for="category_{{$i}}">{{$e}}</label> {{end}}</p>
+   {{end}}
    <p><input type="checkbox" id="dsgvo" name="dsgvo" required><label for="dsgvo">{{.Translation.AcceptPrivacyPolicy}}</label></p>
    <p><input type="submit" value="{{.Translation.RegisterMailRegisterNow}}"></p>
 </form>
@@ -686,6 +708,8 @@ type registerMailRegisterSiteStruct struct {
 	CaptchaID        string
 	Captcha          string
 	RegisterPassword bool
+	Categories       []string
+	Languages        []string
 	Translation      translation.Translation
 }
 
@@ -726,9 +750,11 @@ type registerMailDeleteSiteStruct struct {
 }
 
 type registerMailData struct {
-	Data string
-	Salt string
-	Hash bool
+	Data       string
+	Salt       string
+	Hash       bool
+	Categories []string
+	Language   string
 }
 
 type registerMailQueueObject struct {
@@ -736,6 +762,19 @@ type registerMailQueueObject struct {
 	Announcement   registry.Announcement
 	NumberErrors   int
 	UnsubscribeURL string
+	AnnouncementID string
+	Tracked        bool // Whether the delivery is reported, only true for new and retracted announcements
+	Record         bool // Whether the recipient is recorded in Sent after sending, only true for new announcements
+}
+
+// errRegisterMailExpired is reported for mails which were not sent before the announcement expired.
+var errRegisterMailExpired = errors.New("announcement expired before the mail was sent")
+
+// registerMailDeliveryProgress collects the delivery progress of a single announcement during one round of sending.
+type registerMailDeliveryProgress struct {
+	sent, failed int
+	retrying     bool
+	err          error
 }
 
 type registerMail struct {
@@ -754,8 +793,11 @@ type registerMail struct {
 	RegistrationOpen   bool
 	ServerName         string
 	Queue              []*registerMailQueueObject
+	Sent               map[string][]string // Salts of all recipients who received the announcement with the id
+	SentTime           map[string]time.Time
 
 	l           *sync.Mutex
+	workers     *lifecycle
 	key         string
 	e           chan string
 	description string
@@ -815,6 +857,25 @@ func (r *registerMail) save() error {
 	return err
 }
 
+func (r *registerMail) Start(ctx context.Context) error {
+	r.workers.start(ctx, r.sendWorker)
+	return nil
+}
+
+func (r *registerMail) Stop(ctx context.Context) error {
+	return r.workers.stop(ctx, func() {
+		r.l.Lock()
+		defer r.l.Unlock()
+		// Queued mails are sent after the next start
+		err := r.save()
+		if err != nil {
+			em := fmt.Sprintf("RegisterMail (%s): error while saving queue: %s", r.key, err.Error())
+			log.Println(em)
+			r.e <- em
+		}
+	})
+}
+
 func (r registerMail) GetConfig() template.HTML {
 	counter.StartProcess()
 	defer counter.EndProcess()
@@ -941,29 +1002,185 @@ func (r *registerMail) ProcessConfigChange(req *http.Request) error {
 	return r.save()
 }
 
+func (r *registerMail) Queued() int {
+	r.l.Lock()
+	defer r.l.Unlock()
+	return len(r.Queue)
+}
+
+func (r *registerMail) Settings() []registry.Setting {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	r.l.Lock()
+	defer r.l.Unlock()
+	return []registry.Setting{
+		{Name: "prefix", Value: r.SubjectPrefix},
+		{Name: "from", Value: r.From.String()},
+		{Name: "server", Value: r.SMTPServer},
+		{Name: "port", Value: strconv.Itoa(r.SMTPServerPort)},
+		{Name: "user", Value: r.SMTPUser},
+		{Name: "password", Value: r.SMTPPassword, Secret: true},
+		{Name: "rate", Value: strconv.Itoa(r.RateLimit)},
+		{Name: "registermailtext", Value: r.RegisterMailText},
+		{Name: "unregisterlinktext", Value: r.UnregisterLinkText},
+		{Name: "registerpassword", Value: r.RegisterPassword, Secret: true},
+		{Name: "open", Value: strconv.FormatBool(r.RegistrationOpen)},
+		{Name: "thisserver", Value: r.ServerName},
+	}
+}
+
+func (r *registerMail) Preview(a registry.Announcement) template.HTML {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	r.l.Lock()
+	defer r.l.Unlock()
+	a.Message = strings.Join([]string{a.Message, "\n***\n", r.UnregisterLinkText, fmt.Sprintf("%s/RegisterMail/unsubscribe.html?key=...", r.ServerName)}, "\n\n")
+	return mailPreview(r.SubjectPrefix, a)
+}
+
 func (r *registerMail) NewAnnouncement(a registry.Announcement, id string) {
 	counter.StartProcess()
 	defer counter.EndProcess()
 	r.l.Lock()
 	defer r.l.Unlock()
 
+	recipients := r.queueAnnouncement(a, id, true, true, nil)
+	registry.ReportDeliveryQueued(r.key, id, "RegisterMail", recipients)
+	if recipients == 0 {
+		registry.ReportDeliveryProgress(r.key, id, "RegisterMail", 0, 0, nil)
+	}
+}
+
+func (r *registerMail) UpdateAnnouncement(a registry.Announcement, id string) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	// Queued mails of the announcement are replaced by the updated version,
+	// older corrections which are still queued are superseded
+	pending := make(map[string]bool)
+	queue := make([]*registerMailQueueObject, 0, len(r.Queue))
+	for i := range r.Queue {
+		if r.Queue[i].AnnouncementID != id {
+			queue = append(queue, r.Queue[i])
+			continue
+		}
+		if r.Queue[i].Record {
+			pending[r.Queue[i].To.Salt] = true
+		}
+	}
+	r.Queue = queue
+	if len(pending) != 0 {
+		r.queueAnnouncement(a, id, true, true, pending)
+	}
+
+	// Only recipients who received the announcement get a correction
+	r.queueAnnouncement(correctionMail(a), id, false, false, r.receivedBy(id))
+}
+
+func (r *registerMail) RetractAnnouncement(a registry.Announcement, id string) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	// Don't send mails of the announcement which are still queued
+	r.dropQueued(id)
+
+	// Only recipients who received the announcement are informed
+	received := r.receivedBy(id)
+	delete(r.Sent, id)
+	delete(r.SentTime, id)
+	recipients := r.queueAnnouncement(retractionMail(a), id, true, false, received)
+	registry.ReportDeliveryQueued(r.key, id, "RegisterMail", recipients)
+	if recipients == 0 {
+		registry.ReportDeliveryProgress(r.key, id, "RegisterMail", 0, 0, nil)
+	}
+}
+
+// receivedBy returns the salts of all recipients who received the announcement with the given id.
+func (r *registerMail) receivedBy(id string) map[string]bool {
+	// Caller has to lock l
+	received := make(map[string]bool, len(r.Sent[id]))
+	for _, salt := range r.Sent[id] {
+		received[salt] = true
+	}
+	return received
+}
+
+func (r *registerMail) ExpireAnnouncement(a registry.Announcement, id string) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	// Don't send mails of the announcement which are still queued
+	if r.dropQueued(id) != 0 {
+		registry.ReportDeliveryFailed(r.key, id, "RegisterMail", errRegisterMailExpired)
+	}
+
+	err := r.save()
+	if err != nil {
+		em := fmt.Sprintf("RegisterMail (%s): error while saving queue : %s", r.key, err.Error())
+		log.Println(em)
+		r.e <- em
+	}
+}
+
+// dropQueued removes all queued mails of the announcement and returns the number of dropped tracked mails.
+func (r *registerMail) dropQueued(id string) int {
+	// Caller has to lock l
+	dropped := 0
+	queue := make([]*registerMailQueueObject, 0, len(r.Queue))
+	for i := range r.Queue {
+		if r.Queue[i].AnnouncementID != id {
+			queue = append(queue, r.Queue[i])
+			continue
+		}
+		if r.Queue[i].Tracked {
+			dropped++
+		}
+	}
+	r.Queue = queue
+	return dropped
+}
+
+// queueAnnouncement queues the announcement for all matching recipients and returns the number of recipients.
+// If only is not nil, the announcement is queued for exactly the recipients whose salt is contained in only instead.
+func (r *registerMail) queueAnnouncement(a registry.Announcement, id string, tracked, record bool, only map[string]bool) int {
+	// Caller has to lock l
+	recipients := 0
 	for i := range r.ToData {
 		if r.ToData[i].Hash {
 			// This is no mail address - skip
 			continue
 		}
+		if only != nil && !only[r.ToData[i].Salt] {
+			continue
+		}
+		if only == nil && !a.MatchesCategories(r.ToData[i].Categories) {
+			continue
+		}
 
 		url := fmt.Sprintf("%s/RegisterMail/unsubscribe.html?key=%s&mail=%s", r.ServerName, url.QueryEscape(r.ToData[i].Salt), url.QueryEscape(r.ToData[i].Data))
 
+		la := a.Localised(r.ToData[i].Language)
 		q := new(registerMailQueueObject)
 		q.Announcement = registry.Announcement{
-			Header:  a.Header,
-			Message: strings.Join([]string{a.Message, "\n***\n", r.UnregisterLinkText, url}, "\n\n"),
-			Time:    a.Time,
+			Header:      la.Header,
+			Message:     strings.Join([]string{withPermalink(r.key, la.Message, a), "\n***\n", r.UnregisterLinkText, url}, "\n\n"),
+			Time:        a.Time,
+			Attachments: a.Attachments,
+			Priority:    a.Priority,
 		}
 		q.To = r.ToData[i]
 		q.UnsubscribeURL = url
+		q.AnnouncementID = id
+		q.Tracked = tracked
+		q.Record = record
 		r.Queue = append(r.Queue, q)
+		recipients++
 	}
 
 	err := r.save()
@@ -972,11 +1189,16 @@ func (r *registerMail) NewAnnouncement(a registry.Announcement, id string) {
 		log.Println(em)
 		r.e <- em
 	}
+	return recipients
 }
 
-func (r *registerMail) sendWorker() {
+func (r *registerMail) sendWorker(ctx context.Context) {
 	for {
-		time.Sleep(1 * time.Minute)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(1 * time.Minute):
+		}
 		counter.StartProcess()
 		r.l.Lock()
 
@@ -992,11 +1214,28 @@ func (r *registerMail) sendWorker() {
 			number = len(r.Queue)
 		}
 
+		attachments := newAttachmentCache(r.key)
+		delivery := make(map[string]*registerMailDeliveryProgress)
+		progress := func(q *registerMailQueueObject) *registerMailDeliveryProgress {
+			if !q.Tracked {
+				// Progress is collected but never reported
+				return new(registerMailDeliveryProgress)
+			}
+			if delivery[q.AnnouncementID] == nil {
+				delivery[q.AnnouncementID] = new(registerMailDeliveryProgress)
+			}
+			return delivery[q.AnnouncementID]
+		}
 		process := r.Queue[:number]
 		temp := make([]*registerMailQueueObject, 0, len(r.Queue)-number)
 		r.Queue = append(temp, r.Queue[number:]...)
 
 		for i := range process {
+			if ctx.Err() != nil {
+				// Shutting down - keep the remaining mails at the front of the queue
+				r.Queue = append(append(make([]*registerMailQueueObject, 0, len(r.Queue)+len(process)-i), process[i:]...), r.Queue...)
+				break
+			}
 			if process[i].To.Hash {
 				// This is no mail address - skip
 				continue
@@ -1006,9 +1245,14 @@ func (r *registerMail) sendWorker() {
 			if err != nil {
 				again := "final error"
 				process[i].NumberErrors++
+				p := progress(process[i])
+				p.err = err
 				if process[i].NumberErrors <= registerMailRetries {
 					r.Queue = append(r.Queue, process[i])
 					again = "trying again"
+					p.retrying = true
+				} else {
+					p.failed++
 				}
 				em := fmt.Sprintf("RegisterMail (%s): error while connecting to server (try: %d, %s): %s", r.key, process[i].NumberErrors, again, err.Error())
 				log.Println(em)
@@ -1019,14 +1263,21 @@ func (r *registerMail) sendWorker() {
 			mail.From(r.From.Address)
 			mail.FromName(r.From.Name)
 
-			if r.SubjectPrefix == "" {
-				mail.Subject(process[i].Announcement.Header)
-			} else {
-				mail.Subject(strings.Join([]string{r.SubjectPrefix, process[i].Announcement.Header}, " "))
-			}
+			mail.Subject(mailSubject(r.SubjectPrefix, process[i].Announcement))
+			setMailPriority(mail, process[i].Announcement.Priority)
 
 			mail.Plain().Set(process[i].Announcement.Message)
 			mail.HTML().Set(string(helper.Format([]byte(process[i].Announcement.Message))))
+			err = attachments.attachToMail(mail, process[i].Announcement.Attachments)
+			if err != nil {
+				em := fmt.Sprintf("RegisterMail (%s): error while loading attachments (%s): %s", r.key, process[i].Announcement.Header, err.Error())
+				log.Println(em)
+				r.e <- em
+				p := progress(process[i])
+				p.failed++
+				p.err = err
+				continue
+			}
 
 			if process[i].UnsubscribeURL != "" {
 				mail.AddHeader("List-Unsubscribe", fmt.Sprintf("<%s>", process[i].UnsubscribeURL))
@@ -1039,14 +1290,38 @@ func (r *registerMail) sendWorker() {
 			if err != nil {
 				again := "final error"
 				process[i].NumberErrors++
+				p := progress(process[i])
+				p.err = err
 				if process[i].NumberErrors <= registerMailRetries {
 					r.Queue = append(r.Queue, process[i])
 					again = "trying again"
+					p.retrying = true
+				} else {
+					p.failed++
 				}
 				em := fmt.Sprintf("RegisterMail (%s): error while sending announcement (%s; try: %d, %s): %s", r.key, process[i].Announcement.Header, process[i].NumberErrors, again, err.Error())
 				log.Println(em)
 				r.e <- em
+				continue
+			}
+			progress(process[i]).sent++
+			if process[i].Record {
+				if r.Sent == nil {
+					r.Sent = make(map[string][]string)
+				}
+				if _, ok := r.SentTime[process[i].AnnouncementID]; !ok {
+					r.SentTime[process[i].AnnouncementID] = time.Now()
+				}
+				r.Sent[process[i].AnnouncementID] = append(r.Sent[process[i].AnnouncementID], process[i].To.Salt)
+			}
+		}
+		pruneSent(r.Sent, r.SentTime, time.Now())
+
+		for id, p := range delivery {
+			if p.retrying {
+				registry.ReportDeliveryRetrying(r.key, id, "RegisterMail", p.err)
 			}
+			registry.ReportDeliveryProgress(r.key, id, "RegisterMail", p.sent, p.failed, p.err)
 		}
 		err := r.save()
 		if err != nil {
@@ -0,0 +1,248 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Top-Ranger/announcementgo/registry"
+	"github.com/Top-Ranger/announcementgo/translation"
+)
+
+// testDataSafe keeps the configuration of plugins and the delivery records in memory.
+// All methods not overwritten panic when used.
+type testDataSafe struct {
+	registry.DataSafe
+	m        sync.Mutex
+	config   map[string][]byte
+	delivery map[string][]registry.Delivery
+}
+
+func (t *testDataSafe) SetConfig(key, plugin string, config []byte) error {
+	t.m.Lock()
+	defer t.m.Unlock()
+	if t.config == nil {
+		t.config = make(map[string][]byte)
+	}
+	t.config[strings.Join([]string{key, plugin}, "/")] = config
+	return nil
+}
+
+func (t *testDataSafe) SaveDelivery(key, id string, d registry.Delivery) error {
+	t.m.Lock()
+	defer t.m.Unlock()
+	if t.delivery == nil {
+		t.delivery = make(map[string][]registry.Delivery)
+	}
+	k := strings.Join([]string{key, id}, "/")
+	for i := range t.delivery[k] {
+		if t.delivery[k][i].Plugin == d.Plugin {
+			t.delivery[k][i] = d
+			return nil
+		}
+	}
+	t.delivery[k] = append(t.delivery[k], d)
+	return nil
+}
+
+func (t *testDataSafe) GetDeliveries(key, id string) ([]registry.Delivery, error) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return append([]registry.Delivery(nil), t.delivery[strings.Join([]string{key, id}, "/")]...), nil
+}
+
+// useTestDataSafe sets a testDataSafe as the current data safe for the duration of the test.
+func useTestDataSafe(t *testing.T) *testDataSafe {
+	t.Helper()
+	old := registry.CurrentDataSafe
+	ds := new(testDataSafe)
+	registry.CurrentDataSafe = ds
+	t.Cleanup(func() {
+		registry.CurrentDataSafe = old
+	})
+	return ds
+}
+
+// newTestRegisterMail returns a RegisterMail plugin with the given recipients which is not started.
+func newTestRegisterMail(to ...registerMailData) *registerMail {
+	return &registerMail{
+		ToData:  to,
+		l:       new(sync.Mutex),
+		workers: new(lifecycle),
+		key:     "test",
+		e:       make(chan string, 100),
+	}
+}
+
+func TestRegisterMailRetractVariant(t *testing.T) {
+	useTestDataSafe(t)
+	r := newTestRegisterMail(
+		registerMailData{Data: "en@example.com", Salt: "en"},
+		registerMailData{Data: "de@example.com", Salt: "de", Language: "de"},
+	)
+
+	a := registry.Announcement{
+		Header:    "Header",
+		Message:   "Message",
+		Time:      time.Now(),
+		Retracted: time.Now(),
+		Variants:  []registry.Variant{{Language: "de", Header: "Titel", Message: "Nachricht"}},
+		ID:        "1",
+	}
+	r.Sent = map[string][]string{"1": {"en", "de"}}
+	r.SentTime = map[string]time.Time{"1": time.Now()}
+	r.RetractAnnouncement(a, "1")
+
+	tl := translation.GetDefaultTranslation()
+	if len(r.Queue) != 2 {
+		t.Fatalf("RetractAnnouncement queued %d mails, want 2", len(r.Queue))
+	}
+	for _, q := range r.Queue {
+		header, message := "Header", "Message"
+		if q.To.Language == "de" {
+			header, message = "Titel", "Nachricht"
+		}
+		if q.Announcement.Header != strings.Join([]string{tl.AnnouncementRetracted, header}, ": ") {
+			t.Errorf("mail to %s has header %q, want retracted %q", q.To.Data, q.Announcement.Header, header)
+		}
+		if !strings.HasPrefix(q.Announcement.Message, tl.AnnouncementRetractedText) || !strings.Contains(q.Announcement.Message, message) {
+			t.Errorf("mail to %s has message %q, want retraction notice of %q", q.To.Data, q.Announcement.Message, message)
+		}
+	}
+
+	// The announcement itself must not be changed
+	if a.Variants[0].Header != "Titel" {
+		t.Errorf("RetractAnnouncement changed the variant of the announcement to %q", a.Variants[0].Header)
+	}
+}
+
+func TestRegisterMailRetractRecipients(t *testing.T) {
+	ds := useTestDataSafe(t)
+	r := newTestRegisterMail(
+		registerMailData{Data: "received@example.com", Salt: "received", Categories: []string{"other"}},
+		registerMailData{Data: "later@example.com", Salt: "later"},
+		registerMailData{Data: "queued@example.com", Salt: "queued"},
+	)
+	r.Sent = map[string][]string{"1": {"received", "unsubscribed"}, "2": {"later"}}
+	r.SentTime = map[string]time.Time{"1": time.Now(), "2": time.Now()}
+	r.Queue = []*registerMailQueueObject{{To: r.ToData[2], AnnouncementID: "1", Tracked: true, Record: true}}
+
+	r.RetractAnnouncement(registry.Announcement{Header: "Header", Message: "Message", Categories: []string{"category"}, ID: "1"}, "1")
+
+	if len(r.Queue) != 1 {
+		t.Fatalf("RetractAnnouncement queued %d mails, want 1", len(r.Queue))
+	}
+	q := r.Queue[0]
+	if q.To.Salt != "received" {
+		t.Errorf("retraction queued for %s, want only the recipient of the announcement", q.To.Data)
+	}
+	if q.AnnouncementID != "1" || !q.Tracked || q.Record {
+		t.Errorf("retraction queued with id %q, tracked %t, record %t, want tracked under the announcement id without record", q.AnnouncementID, q.Tracked, q.Record)
+	}
+	if _, ok := r.Sent["1"]; ok {
+		t.Error("record of retracted announcement was kept")
+	}
+	if _, ok := r.Sent["2"]; !ok {
+		t.Error("record of other announcement was removed")
+	}
+
+	d, _ := ds.GetDeliveries("test", "1")
+	if len(d) != 1 || d[0].State != registry.DeliveryQueued || d[0].Recipients != 1 {
+		t.Errorf("delivery of retraction = %+v, want queued for 1 recipient", d)
+	}
+}
+
+func TestRegisterMailCorrectionVariant(t *testing.T) {
+	useTestDataSafe(t)
+	r := newTestRegisterMail(registerMailData{Data: "de@example.com", Salt: "de", Language: "de"})
+
+	a := registry.Announcement{
+		Header:   "Header",
+		Message:  "Message",
+		Time:     time.Now(),
+		Variants: []registry.Variant{{Language: "de", Header: "Titel", Message: "Nachricht"}},
+		ID:       "1",
+	}
+	r.Sent = map[string][]string{"1": {"de"}}
+	r.SentTime = map[string]time.Time{"1": time.Now()}
+	r.UpdateAnnouncement(a, "1")
+
+	want := strings.Join([]string{translation.GetDefaultTranslation().AnnouncementCorrection, "Titel"}, ": ")
+	if len(r.Queue) != 1 || r.Queue[0].Announcement.Header != want {
+		t.Fatalf("UpdateAnnouncement queued %+v, want a single mail with header %q", r.Queue, want)
+	}
+}
+
+func TestRegisterMailCorrectionRecipients(t *testing.T) {
+	useTestDataSafe(t)
+	r := newTestRegisterMail(
+		registerMailData{Data: "received@example.com", Salt: "received"},
+		registerMailData{Data: "queued@example.com", Salt: "queued"},
+		registerMailData{Data: "later@example.com", Salt: "later"},
+	)
+	r.Sent = map[string][]string{"1": {"received"}}
+	r.SentTime = map[string]time.Time{"1": time.Now()}
+	r.Queue = []*registerMailQueueObject{
+		{To: r.ToData[1], AnnouncementID: "1", Announcement: registry.Announcement{Header: "Old"}, Tracked: true, Record: true},
+		{To: r.ToData[0], AnnouncementID: "1", Announcement: registry.Announcement{Header: "Old correction"}},
+	}
+
+	r.UpdateAnnouncement(registry.Announcement{Header: "Header", Message: "Message", ID: "1"}, "1")
+
+	correction := strings.Join([]string{translation.GetDefaultTranslation().AnnouncementCorrection, "Header"}, ": ")
+	want := map[string]string{"queued": "Header", "received": correction}
+	if len(r.Queue) != len(want) {
+		t.Fatalf("UpdateAnnouncement queued %d mails, want %d", len(r.Queue), len(want))
+	}
+	for _, q := range r.Queue {
+		if q.Announcement.Header != want[q.To.Salt] {
+			t.Errorf("mail for %s has header %q, want %q", q.To.Data, q.Announcement.Header, want[q.To.Salt])
+		}
+		if q.To.Salt == "queued" && (!q.Tracked || !q.Record) {
+			t.Errorf("updated mail for %s is not tracked and recorded", q.To.Data)
+		}
+	}
+}
+
+func TestRegisterMailExpireQueued(t *testing.T) {
+	ds := useTestDataSafe(t)
+	r := newTestRegisterMail(
+		registerMailData{Data: "sent@example.com", Salt: "sent"},
+		registerMailData{Data: "queued@example.com", Salt: "queued"},
+	)
+	registry.ReportDeliveryQueued("test", "1", "RegisterMail", 2)
+	registry.ReportDeliveryProgress("test", "1", "RegisterMail", 1, 0, nil)
+	r.Queue = []*registerMailQueueObject{
+		{To: r.ToData[1], AnnouncementID: "1", Tracked: true, Record: true},
+		{To: r.ToData[1], AnnouncementID: "2", Tracked: true, Record: true},
+	}
+
+	r.ExpireAnnouncement(registry.Announcement{Header: "Header", Message: "Message", ID: "1"}, "1")
+
+	if len(r.Queue) != 1 || r.Queue[0].AnnouncementID != "2" {
+		t.Errorf("ExpireAnnouncement kept %+v, want only mails of other announcements", r.Queue)
+	}
+	d, _ := ds.GetDeliveries("test", "1")
+	if len(d) != 1 || d[0].State != registry.DeliveryFailed || d[0].Sent != 1 || d[0].Failed != 1 {
+		t.Errorf("delivery = %+v, want dropped mail reported as failed", d)
+	}
+	if d, _ := ds.GetDeliveries("test", "2"); len(d) != 0 {
+		t.Errorf("delivery of other announcement = %+v, want unchanged", d)
+	}
+}
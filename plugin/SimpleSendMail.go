@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020 Marcus Soll
+// Copyright 2020,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -17,6 +17,7 @@ package plugin
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/gob"
 	"fmt"
@@ -149,6 +150,16 @@ func (s simpleSendMail) verify() bool {
 	return true
 }
 
+func (s *simpleSendMail) Start(ctx context.Context) error {
+	// Mails are sent directly, there is no background work
+	return nil
+}
+
+func (s *simpleSendMail) Stop(ctx context.Context) error {
+	// Mails are sent directly, there is no background work
+	return nil
+}
+
 func (s simpleSendMail) GetConfig() template.HTML {
 	counter.StartProcess()
 	defer counter.EndProcess()
@@ -271,17 +282,79 @@ func (s *simpleSendMail) ProcessConfigChange(r *http.Request) error {
 	return err
 }
 
+func (s *simpleSendMail) Settings() []registry.Setting {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	s.l.Lock()
+	defer s.l.Unlock()
+	to := make([]string, len(s.To))
+	for i := range s.To {
+		to[i] = s.To[i].String()
+	}
+	return []registry.Setting{
+		{Name: "prefix", Value: s.SubjectPrefix},
+		{Name: "from", Value: s.From.String()},
+		{Name: "to", Value: strings.Join(to, ", ")},
+		{Name: "server", Value: s.SMTPServer},
+		{Name: "port", Value: strconv.Itoa(s.SMTPServerPort)},
+		{Name: "user", Value: s.SMTPUser},
+		{Name: "password", Value: s.SMTPPassword, Secret: true},
+	}
+}
+
+func (s *simpleSendMail) Preview(a registry.Announcement) template.HTML {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	s.l.Lock()
+	defer s.l.Unlock()
+	return mailPreview(s.SubjectPrefix, a)
+}
+
 func (s *simpleSendMail) NewAnnouncement(a registry.Announcement, id string) {
 	counter.StartProcess()
 	defer counter.EndProcess()
 	s.l.Lock()
 	defer s.l.Unlock()
 
+	// All recipients get the same mail
+	registry.ReportDeliveryQueued(s.key, id, "SimpleSendMail", len(s.To))
+	err := s.send(a)
+	if err != nil {
+		registry.ReportDeliveryFailed(s.key, id, "SimpleSendMail", err)
+		return
+	}
+	registry.ReportDeliveryProgress(s.key, id, "SimpleSendMail", len(s.To), 0, nil)
+}
+
+func (s *simpleSendMail) UpdateAnnouncement(a registry.Announcement, id string) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	s.send(correctionMail(a))
+}
+
+func (s *simpleSendMail) RetractAnnouncement(a registry.Announcement, id string) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	s.send(retractionMail(a))
+}
+
+func (s *simpleSendMail) ExpireAnnouncement(a registry.Announcement, id string) {
+	// Mails can not be taken back - nothing to do
+}
+
+func (s *simpleSendMail) send(a registry.Announcement) error {
+	// Caller has to lock l
 	if !s.verify() {
 		em := fmt.Sprintf("SimpleSendMail (%s): no valid configuration, can not send announcement (%s)", s.key, a.Header)
 		log.Println(em)
 		s.e <- em
-		return
+		return errNotConfigured
 	}
 
 	mail, err := mailyak.NewWithTLS(fmt.Sprint(s.SMTPServer, ":", strconv.Itoa(s.SMTPServerPort)), smtp.PlainAuth("", s.SMTPUser, s.SMTPPassword, s.SMTPServer), &tls.Config{ServerName: s.SMTPServer, MinVersion: tls.VersionTLS12})
@@ -289,7 +362,7 @@ func (s *simpleSendMail) NewAnnouncement(a registry.Announcement, id string) {
 		em := fmt.Sprintf("RegisterMail (%s): error while connecting to server: %s", s.key, err.Error())
 		log.Println(em)
 		s.e <- em
-		return
+		return err
 	}
 
 	mail.From(s.From.Address)
@@ -303,18 +376,24 @@ func (s *simpleSendMail) NewAnnouncement(a registry.Announcement, id string) {
 	}
 	mail.To(tos...)
 
-	if s.SubjectPrefix == "" {
-		mail.Subject(a.Header)
-	} else {
-		mail.Subject(strings.Join([]string{s.SubjectPrefix, a.Header}, " "))
-	}
+	mail.Subject(mailSubject(s.SubjectPrefix, a))
+	setMailPriority(mail, a.Priority)
 
-	mail.Plain().Set(a.Message)
-	mail.HTML().Set(string(helper.Format([]byte(a.Message))))
+	message := withPermalink(s.key, a.Message, a)
+	mail.Plain().Set(message)
+	mail.HTML().Set(string(helper.Format([]byte(message))))
+	err = newAttachmentCache(s.key).attachToMail(mail, a.Attachments)
+	if err != nil {
+		em := fmt.Sprintf("SimpleSendMail (%s): error while loading attachments (%s): %s", s.key, a.Header, err.Error())
+		log.Println(em)
+		s.e <- em
+		return err
+	}
 	err = mail.Send()
 	if err != nil {
 		em := fmt.Sprintf("SimpleSendMail (%s): error while sending announcement (%s): %s", s.key, a.Header, err.Error())
 		log.Println(em)
 		s.e <- em
 	}
+	return err
 }
@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020,2021 Marcus Soll
+// Copyright 2020,2021,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -17,12 +17,14 @@ package plugin
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -80,7 +82,11 @@ func telegramFactory(key, shortDescription string, errorChannel chan string) (re
 			}
 		}
 	}
+	if t.SentTime == nil {
+		t.SentTime = make(map[string]time.Time)
+	}
 	t.l = new(sync.Mutex)
+	t.workers = new(lifecycle)
 	t.key = key
 	t.e = errorChannel
 
@@ -89,8 +95,6 @@ func telegramFactory(key, shortDescription string, errorChannel chan string) (re
 
 	err = t.update()
 
-	go t.sendWorker()
-
 	return t, err
 }
 
@@ -104,6 +108,7 @@ const telegramConfig = `
 <form method="POST">
 	<input type="hidden" name="target" value="Telegram">
 	<p><input id="Telegram_token" type="text" name="token" value="{{.Token}}" placeholder="token"> <label for="Telegram_token">Telegram Bot API token</label></p>
+	<p><input id="Telegram_deleteexpired" type="checkbox" name="deleteexpired" {{if .DeleteExpired}}checked{{end}}> <label for="Telegram_deleteexpired">delete messages of expired announcements</label></p>
 	<p><input type="submit" value="Update"></p>
 </form>
 `
@@ -118,23 +123,63 @@ type telegramConfigTemplateStruct struct {
 	Token               string
 	UserNumber          int
 	URL                 string
+	DeleteExpired       bool
 }
 
+const (
+	telegramActionSend = iota
+	telegramActionEdit
+	telegramActionDelete
+	telegramActionDocument
+)
+
+// telegramMessage represents an entry in the send queue.
+// For telegramActionDelete, all sent parts starting with Part are deleted. Documents are only deleted if Part is 0.
+// For telegramActionDocument, Attachment is sent instead of Message.
 type telegramMessage struct {
-	Target  int64
-	Message string
-	Silent  bool
+	Target         int64
+	Message        string
+	Silent         bool
+	AnnouncementID string
+	Part           int
+	Action         int
+	Attachment     registry.Attachment
+}
+
+type telegramSentMessage struct {
+	Target    int64
+	MessageID int
+	Part      int
+	Document  bool
+}
+
+// telegramDeliveryInterval is the maximum time delivery progress is collected before it is reported.
+const telegramDeliveryInterval = 1 * time.Minute
+
+// telegramDeliveryProgress collects the delivery progress of a single announcement until it is reported.
+type telegramDeliveryProgress struct {
+	sent, failed int
+	err          error
+	since        time.Time
 }
 
 type telegram struct {
-	Token       string
-	TokenHidden bool
-	Targets     []int64
-	Messages    []telegramMessage
+	Token            string
+	TokenHidden      bool
+	DeleteExpired    bool
+	Targets          []int64
+	TargetCategories map[int64][]string
+	TargetLanguages  map[int64]string
+	Messages         []telegramMessage
+	Sent             map[string][]telegramSentMessage
+	SentTime         map[string]time.Time
 
 	bot          *telebot.Bot
 	currentToken string
+	running      bool // bot is only connected between Start and Stop
+	delivery     map[string]*telegramDeliveryProgress
 	l            *sync.Mutex
+	workers      *lifecycle
 	e            chan string
 	key          string
 }
@@ -152,7 +197,7 @@ func (t *telegram) update() error {
 		}
 	}
 
-	if t.bot == nil && t.Token != "" {
+	if t.bot == nil && t.Token != "" && t.running {
 		var err error
 		t.bot, err = telebot.NewBot(telebot.Settings{
 			Token:  t.Token,
@@ -196,6 +241,13 @@ func (t *telegram) update() error {
 			if !found {
 				t.Targets = append(t.Targets, newID)
 			}
+			// Only private chats have a well-defined language
+			if sender := c.Sender(); chat.Type == telebot.ChatPrivate && sender != nil && sender.LanguageCode != "" {
+				if t.TargetLanguages == nil {
+					t.TargetLanguages = make(map[int64]string)
+				}
+				t.TargetLanguages[newID] = sender.LanguageCode
+			}
 			if newID != int64(t.bot.Me.ID) {
 				err = c.Send(translation.GetDefaultTranslation().BotUserGreetings, telebot.NoPreview)
 				if err != nil {
@@ -211,6 +263,79 @@ func (t *telegram) update() error {
 		t.bot.Handle(telebot.OnAddedToGroup, addedFunction)
 		t.bot.Handle("/start", addedFunction)
 
+		categoriesFunction := func(c telebot.Context) error {
+			counter.StartProcess()
+			defer counter.EndProcess()
+			t.l.Lock()
+			defer t.l.Unlock()
+
+			chat := c.Chat()
+			if chat == nil {
+				em := fmt.Sprintln("telegram (categoriesFunction): chat should not be nil, but it is")
+				log.Println(em)
+				t.e <- em
+				return err
+			}
+
+			tl := translation.GetDefaultTranslation()
+			available := registry.GetCategories(t.key)
+			if len(available) == 0 {
+				return c.Send(tl.BotCategoriesNone, telebot.NoPreview)
+			}
+
+			found := false
+			for i := range t.Targets {
+				if t.Targets[i] == chat.ID {
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Targets = append(t.Targets, chat.ID)
+			}
+
+			// Commands might be sent as /command@botname in groups
+			command := strings.SplitN(strings.Fields(c.Text())[0], "@", 2)[0]
+			category := strings.TrimSpace(c.Message().Payload)
+			switch command {
+			case "/subscribe", "/unsubscribe":
+				if len(registry.FilterCategories(t.key, []string{category})) == 0 {
+					return c.Send(strings.Join([]string{tl.BotCategoryUnknown, tl.BotCategoriesHelp}, "\n\n"), telebot.NoPreview)
+				}
+				selection := make([]string, 0, len(t.TargetCategories[chat.ID])+1)
+				for i := range t.TargetCategories[chat.ID] {
+					if t.TargetCategories[chat.ID][i] != category {
+						selection = append(selection, t.TargetCategories[chat.ID][i])
+					}
+				}
+				if command == "/subscribe" {
+					selection = append(selection, category)
+				}
+				t.setTargetCategories(chat.ID, registry.FilterCategories(t.key, selection))
+			case "/all":
+				t.setTargetCategories(chat.ID, nil)
+			}
+
+			text := tl.BotCategoriesAll
+			if len(t.TargetCategories[chat.ID]) != 0 {
+				text = fmt.Sprintf("%s: %s", tl.BotCategoriesSelected, strings.Join(t.TargetCategories[chat.ID], ", "))
+			}
+			text = fmt.Sprintf("%s\n\n%s: %s\n\n%s", text, tl.BotCategoriesAvailable, strings.Join(available, ", "), tl.BotCategoriesHelp)
+			err = c.Send(text, telebot.NoPreview)
+			if err != nil {
+				em := fmt.Sprintln("telegram:", err)
+				log.Println(em)
+				t.e <- em
+				return err
+			}
+			return t.update()
+		}
+
+		t.bot.Handle("/categories", categoriesFunction)
+		t.bot.Handle("/subscribe", categoriesFunction)
+		t.bot.Handle("/unsubscribe", categoriesFunction)
+		t.bot.Handle("/all", categoriesFunction)
+
 		messageFunc := func(c telebot.Context) error {
 			counter.StartProcess()
 			defer counter.EndProcess()
@@ -263,6 +388,14 @@ func (t *telegram) update() error {
 					t.Targets[i] = to
 				}
 			}
+			if categories, ok := t.TargetCategories[from]; ok {
+				t.setTargetCategories(to, categories)
+				t.setTargetCategories(from, nil)
+			}
+			if language, ok := t.TargetLanguages[from]; ok {
+				t.TargetLanguages[to] = language
+				delete(t.TargetLanguages, from)
+			}
 			return t.update()
 		})
 
@@ -270,6 +403,12 @@ func (t *telegram) update() error {
 		go t.bot.Start()
 	}
 
+	return t.save()
+}
+
+// save saves the configuration including all queued messages.
+func (t *telegram) save() error {
+	// Caller has to lock
 	tmpToken := t.Token
 	t.Token = helper.HidePassword(t.Token)
 	t.TokenHidden = true
@@ -295,6 +434,47 @@ func (t *telegram) update() error {
 	return nil
 }
 
+func (t *telegram) Start(ctx context.Context) error {
+	t.l.Lock()
+	t.running = true
+	err := t.update()
+	t.l.Unlock()
+	if err != nil {
+		return err
+	}
+	t.workers.start(ctx, t.sendWorker, t.pollWorker)
+	return nil
+}
+
+func (t *telegram) Stop(ctx context.Context) error {
+	return t.workers.stop(ctx, func() {
+		t.l.Lock()
+		defer t.l.Unlock()
+		t.disconnect()
+		t.reportCollectedDelivery(true)
+		// Queued messages are sent after the next start
+		t.save()
+	})
+}
+
+// pollWorker disconnects the bot once ctx is done. Polling itself is started by update.
+func (t *telegram) pollWorker(ctx context.Context) {
+	<-ctx.Done()
+	t.l.Lock()
+	defer t.l.Unlock()
+	t.disconnect()
+}
+
+// disconnect stops polling and prevents the bot from connecting again.
+func (t *telegram) disconnect() {
+	// Caller has to lock
+	t.running = false
+	if t.bot != nil {
+		t.bot.Stop()
+		t.bot = nil
+	}
+}
+
 func (t *telegram) GetConfig() template.HTML {
 	counter.StartProcess()
 	defer counter.EndProcess()
@@ -302,10 +482,11 @@ func (t *telegram) GetConfig() template.HTML {
 	defer t.l.Unlock()
 
 	td := telegramConfigTemplateStruct{
-		Valid:      t.bot != nil,
-		Token:      t.Token,
-		UserNumber: len(t.Targets),
-		URL:        "Create bot: https://core.telegram.org/bots#3-how-do-i-create-a-bot",
+		Valid:         t.bot != nil,
+		Token:         t.Token,
+		UserNumber:    len(t.Targets),
+		URL:           "Create bot: https://core.telegram.org/bots#3-how-do-i-create-a-bot",
+		DeleteExpired: t.DeleteExpired,
 	}
 	td.ConfigValidFragment = helper.ConfigInvalid
 	if td.Valid {
@@ -335,6 +516,7 @@ func (t *telegram) ProcessConfigChange(r *http.Request) error {
 	defer t.l.Unlock()
 
 	t.Token = r.Form.Get("token")
+	t.DeleteExpired = r.Form.Get("deleteexpired") != ""
 
 	err = t.update()
 	if err != nil {
@@ -347,19 +529,207 @@ func (t *telegram) ProcessConfigChange(r *http.Request) error {
 	return nil
 }
 
+func (t *telegram) Queued() int {
+	t.l.Lock()
+	defer t.l.Unlock()
+	return len(t.Messages)
+}
+
+func (t *telegram) Settings() []registry.Setting {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	t.l.Lock()
+	defer t.l.Unlock()
+	return []registry.Setting{
+		{Name: "token", Value: t.Token, Secret: true},
+		{Name: "deleteexpired", Value: strconv.FormatBool(t.DeleteExpired)},
+	}
+}
+
+func (t *telegram) Preview(a registry.Announcement) template.HTML {
+	counter.StartProcess()
+	defer counter.EndProcess()
+
+	messageParts := t.splitMessage(a)
+	parts := make([]previewPart, 0, len(messageParts)+1)
+	for mp := range messageParts {
+		silent := mp != 0
+		switch a.Priority {
+		case registry.PriorityLow:
+			silent = true
+		case registry.PriorityUrgent:
+			silent = false
+		}
+		title := fmt.Sprintf("Message %d", mp+1)
+		if silent {
+			title = fmt.Sprintf("Message %d (silent)", mp+1)
+		}
+		m, err := t.formatMessage(messageParts[mp])
+		if err != nil {
+			parts = append(parts, previewPart{Title: title, Text: err.Error()})
+			continue
+		}
+		// formatMessage only returns HTML allowed by Telegram
+		parts = append(parts, previewPart{Title: title, HTML: template.HTML(m), PreserveLines: true})
+	}
+	if p, ok := attachmentPreview(a); ok {
+		parts = append(parts, p)
+	}
+	return renderPreview(parts)
+}
+
 func (t *telegram) NewAnnouncement(a registry.Announcement, id string) {
 	counter.StartProcess()
 	defer counter.EndProcess()
 	t.l.Lock()
 	defer t.l.Unlock()
 
+	if t.bot == nil {
+		// no bot configurated - jump out
+		registry.ReportDeliveryFailed(t.key, id, "Telegram", errNotConfigured)
+		return
+	}
+
+	// Messages are split once per language
+	languageParts := make(map[string][]string)
+	recipients := 0
+
+	for tar := range t.Targets {
+		if !a.MatchesCategories(t.TargetCategories[t.Targets[tar]]) {
+			continue
+		}
+		recipients++
+		language := t.TargetLanguages[t.Targets[tar]]
+		messageParts, ok := languageParts[language]
+		if !ok {
+			messageParts = t.splitMessage(a.Localised(language))
+			languageParts[language] = messageParts
+		}
+		for mp := range messageParts {
+			// Normally only the first part triggers a notification
+			silent := mp != 0
+			switch a.Priority {
+			case registry.PriorityLow:
+				silent = true
+			case registry.PriorityUrgent:
+				silent = false
+			}
+			t.Messages = append(t.Messages, telegramMessage{Message: messageParts[mp], Target: t.Targets[tar], Silent: silent, AnnouncementID: id, Part: mp})
+		}
+		for at := range a.Attachments {
+			t.Messages = append(t.Messages, telegramMessage{Target: t.Targets[tar], Silent: true, AnnouncementID: id, Part: len(messageParts) + at, Action: telegramActionDocument, Attachment: a.Attachments[at]})
+		}
+	}
+
+	registry.ReportDeliveryQueued(t.key, id, "Telegram", recipients)
+	if recipients == 0 {
+		registry.ReportDeliveryProgress(t.key, id, "Telegram", 0, 0, nil)
+	}
+
+	err := t.update()
+	if err != nil {
+		em := fmt.Sprintln("telegram:", err)
+		log.Println(em)
+		t.e <- em
+	}
+}
+
+func (t *telegram) UpdateAnnouncement(a registry.Announcement, id string) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	t.l.Lock()
+	defer t.l.Unlock()
+
 	if t.bot == nil {
 		// no bot configurated - jump out
 		return
 	}
 
-	// Include Header
-	a.Message = strings.Join([]string{a.Header, a.Message}, "\n\n")
+	// Messages are split once per language
+	languageParts := make(map[string][]string)
+
+	for tar := range t.Targets {
+		if !a.MatchesCategories(t.TargetCategories[t.Targets[tar]]) {
+			continue
+		}
+		language := t.TargetLanguages[t.Targets[tar]]
+		messageParts, ok := languageParts[language]
+		if !ok {
+			messageParts = t.splitMessage(a.Localised(language))
+			languageParts[language] = messageParts
+		}
+		for mp := range messageParts {
+			t.Messages = append(t.Messages, telegramMessage{Message: messageParts[mp], Target: t.Targets[tar], Silent: true, AnnouncementID: id, Part: mp, Action: telegramActionEdit})
+		}
+		// Remove parts no longer needed
+		t.Messages = append(t.Messages, telegramMessage{Target: t.Targets[tar], AnnouncementID: id, Part: len(messageParts), Action: telegramActionDelete})
+	}
+
+	err := t.update()
+	if err != nil {
+		em := fmt.Sprintln("telegram:", err)
+		log.Println(em)
+		t.e <- em
+	}
+}
+
+func (t *telegram) RetractAnnouncement(a registry.Announcement, id string) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	t.remove(id)
+}
+
+func (t *telegram) ExpireAnnouncement(a registry.Announcement, id string) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	if !t.DeleteExpired {
+		// Messages of expired announcements are no longer edited
+		t.forgetSent(id)
+		err := t.update()
+		if err != nil {
+			em := fmt.Sprintln("telegram:", err)
+			log.Println(em)
+			t.e <- em
+		}
+		return
+	}
+
+	t.remove(id)
+}
+
+func (t *telegram) remove(id string) {
+	// Caller has to lock
+
+	// Don't send parts of the announcement which are still queued
+	messages := make([]telegramMessage, 0, len(t.Messages))
+	for i := range t.Messages {
+		if t.Messages[i].AnnouncementID != id {
+			messages = append(messages, t.Messages[i])
+		}
+	}
+	t.Messages = messages
+
+	for tar := range t.Targets {
+		t.Messages = append(t.Messages, telegramMessage{Target: t.Targets[tar], AnnouncementID: id, Part: 0, Action: telegramActionDelete})
+	}
+
+	err := t.update()
+	if err != nil {
+		em := fmt.Sprintln("telegram:", err)
+		log.Println(em)
+		t.e <- em
+	}
+}
+
+func (t *telegram) splitMessage(a registry.Announcement) []string {
+	// Include Header and link
+	a.Message = withPermalink(t.key, strings.Join([]string{a.Header, a.Message}, "\n\n"), a)
 
 	messageParts := make([]string, 0)
 	parts := 0
@@ -387,30 +757,23 @@ func (t *telegram) NewAnnouncement(a registry.Announcement, id string) {
 			messageParts[i] = fmt.Sprintf("[%d/%d]\n%s", i+1, parts+1, messageParts[i])
 		}
 	}
-
-	for tar := range t.Targets {
-		for mp := range messageParts {
-			t.Messages = append(t.Messages, telegramMessage{Message: messageParts[mp], Target: t.Targets[tar], Silent: mp != 0})
-		}
-	}
-
-	err := t.update()
-	if err != nil {
-		em := fmt.Sprintln("telegram:", err)
-		log.Println(em)
-		t.e <- em
-	}
+	return messageParts
 }
 
-func (t *telegram) sendWorker() {
+func (t *telegram) sendWorker(ctx context.Context) {
 	for {
-		time.Sleep(2 * time.Second)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(2 * time.Second):
+		}
 
 		func() {
 			counter.StartProcess()
 			defer counter.EndProcess()
 			t.l.Lock()
 			defer t.l.Unlock()
+			defer t.reportCollectedDelivery(false)
 
 			if t.bot == nil {
 				return
@@ -435,24 +798,67 @@ func (t *telegram) sendWorker() {
 			}
 
 			if !ok {
+				t.reportDelivery(message, fmt.Errorf("unknown target %d", message.Target))
 				return
 			}
 
-			// Format message
-			message.Message, err = t.formatMessage(message.Message)
-			if err != nil {
-				em := fmt.Sprintln("telegram:", err)
-				log.Println(em)
-				t.e <- em
+			if message.Action == telegramActionDelete {
+				t.deleteSent(message.AnnouncementID, message.Target, message.Part)
+				err = t.update()
+				if err != nil {
+					em := fmt.Sprintln("telegram:", err)
+					log.Println(em)
+					t.e <- em
+				}
 				return
 			}
 
+			// Format message
+			if message.Action != telegramActionDocument {
+				message.Message, err = t.formatMessage(message.Message)
+				if err != nil {
+					em := fmt.Sprintln("telegram:", err)
+					log.Println(em)
+					t.e <- em
+					t.reportDelivery(message, err)
+					return
+				}
+			}
+
+			if message.Action == telegramActionEdit {
+				edited := false
+				received := false
+				for _, s := range t.Sent[message.AnnouncementID] {
+					if s.Target != message.Target {
+						continue
+					}
+					received = true
+					if s.Part != message.Part || s.Document {
+						continue
+					}
+					_, err = t.bot.Edit(telebot.StoredMessage{MessageID: strconv.Itoa(s.MessageID), ChatID: s.Target}, message.Message, &telebot.SendOptions{DisableWebPagePreview: true, ParseMode: telebot.ModeHTML})
+					if err != nil && err != telebot.ErrSameMessageContent && err != telebot.ErrMessageNotModified {
+						em := fmt.Sprintln("telegram:", err)
+						log.Println(em)
+						t.e <- em
+					}
+					edited = true
+					break
+				}
+				if edited || !received {
+					// Targets which never received the announcement (e.g. later subscribers) don't get edits
+					return
+				}
+				// Part was never sent (e.g. new part) - send it instead
+			}
+
 			// Send message
 			c, err := t.bot.ChatByID(message.Target)
 			if err != nil {
 				em := fmt.Sprintln("telegram:", err)
 				log.Println(em)
 				t.e <- em
+				t.reportDelivery(message, err)
 				t.removeTarget(message.Target)
 				err = t.update()
 				if err != nil {
@@ -463,7 +869,20 @@ func (t *telegram) sendWorker() {
 				return
 			}
 
-			_, err = t.bot.Send(c, message.Message, &telebot.SendOptions{DisableWebPagePreview: true, ParseMode: telebot.ModeHTML, DisableNotification: message.Silent})
+			var content interface{} = message.Message
+			if message.Action == telegramActionDocument {
+				b, err := registry.CurrentDataSafe.GetAttachment(t.key, message.Attachment.ID)
+				if err != nil {
+					em := fmt.Sprintln("telegram:", err)
+					log.Println(em)
+					t.e <- em
+					return
+				}
+				content = &telebot.Document{File: telebot.FromReader(bytes.NewReader(b)), FileName: message.Attachment.Name, MIME: message.Attachment.ContentType}
+			}
+
+			m, err := t.bot.Send(c, content, &telebot.SendOptions{DisableWebPagePreview: true, ParseMode: telebot.ModeHTML, DisableNotification: message.Silent})
+			t.reportDelivery(message, err)
 			if err != nil {
 
 				apierror, ok := err.(*telebot.Error)
@@ -479,6 +898,15 @@ func (t *telegram) sendWorker() {
 					log.Println(em)
 					t.e <- em
 				}
+			} else if message.AnnouncementID != "" {
+				if t.Sent == nil {
+					t.Sent = make(map[string][]telegramSentMessage)
+				}
+				if _, ok := t.SentTime[message.AnnouncementID]; !ok {
+					t.SentTime[message.AnnouncementID] = time.Now()
+				}
+				t.Sent[message.AnnouncementID] = append(t.Sent[message.AnnouncementID], telegramSentMessage{Target: message.Target, MessageID: m.ID, Part: message.Part, Document: message.Action == telegramActionDocument})
+				pruneSent(t.Sent, t.SentTime, time.Now())
 			}
 			err = t.update()
 			if err != nil {
@@ -490,6 +918,82 @@ func (t *telegram) sendWorker() {
 	}
 }
 
+// reportDelivery collects the result of sending a message until it is reported by reportCollectedDelivery.
+// Only the first part of a new announcement is counted, since it decides whether the target received the announcement.
+func (t *telegram) reportDelivery(message telegramMessage, err error) {
+	// Caller has to lock
+	if message.Action != telegramActionSend || message.Part != 0 || message.AnnouncementID == "" {
+		return
+	}
+	if t.delivery == nil {
+		t.delivery = make(map[string]*telegramDeliveryProgress)
+	}
+	p, ok := t.delivery[message.AnnouncementID]
+	if !ok {
+		p = &telegramDeliveryProgress{since: time.Now()}
+		t.delivery[message.AnnouncementID] = p
+	}
+	if err != nil {
+		p.failed++
+		p.err = err
+		return
+	}
+	p.sent++
+}
+
+// reportCollectedDelivery reports the collected delivery progress of all announcements which have no first part queued any more
+// or whose progress was collected longer than telegramDeliveryInterval. If all is true, all progress is reported.
+func (t *telegram) reportCollectedDelivery(all bool) {
+	// Caller has to lock
+	if len(t.delivery) == 0 {
+		return
+	}
+	queued := make(map[string]bool)
+	if !all {
+		for i := range t.Messages {
+			if t.Messages[i].Action == telegramActionSend && t.Messages[i].Part == 0 {
+				queued[t.Messages[i].AnnouncementID] = true
+			}
+		}
+	}
+	for id, p := range t.delivery {
+		if queued[id] && time.Since(p.since) < telegramDeliveryInterval {
+			continue
+		}
+		registry.ReportDeliveryProgress(t.key, id, "Telegram", p.sent, p.failed, p.err)
+		delete(t.delivery, id)
+	}
+}
+
+func (t *telegram) deleteSent(id string, target int64, fromPart int) {
+	// Caller has to lock and save
+	keep := make([]telegramSentMessage, 0, len(t.Sent[id]))
+	for _, s := range t.Sent[id] {
+		if s.Target != target || s.Part < fromPart || (s.Document && fromPart != 0) {
+			keep = append(keep, s)
+			continue
+		}
+		err := t.bot.Delete(telebot.StoredMessage{MessageID: strconv.Itoa(s.MessageID), ChatID: s.Target})
+		if err != nil {
+			em := fmt.Sprintln("telegram:", err)
+			log.Println(em)
+			t.e <- em
+		}
+	}
+	if len(keep) == 0 {
+		t.forgetSent(id)
+		return
+	}
+	t.Sent[id] = keep
+}
+
+// forgetSent removes all records of sent messages of the announcement without deleting the messages.
+func (t *telegram) forgetSent(id string) {
+	// Caller has to lock and save
+	delete(t.Sent, id)
+	delete(t.SentTime, id)
+}
+
 func (t *telegram) removeTarget(target int64) {
 	// Caller has to lock and save
 	newIDs := make([]int64, 0, len(t.Targets))
@@ -499,6 +1003,20 @@ func (t *telegram) removeTarget(target int64) {
 		}
 	}
 	t.Targets = newIDs
+	t.setTargetCategories(target, nil)
+	delete(t.TargetLanguages, target)
+}
+
+func (t *telegram) setTargetCategories(target int64, categories []string) {
+	// Caller has to lock and save
+	if len(categories) == 0 {
+		delete(t.TargetCategories, target)
+		return
+	}
+	if t.TargetCategories == nil {
+		t.TargetCategories = make(map[int64][]string)
+	}
+	t.TargetCategories[target] = categories
 }
 
 func (t *telegram) formatMessage(message string) (string, error) {
@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Top-Ranger/announcementgo/registry"
+)
+
+func TestTelegramReportDelivery(t *testing.T) {
+	ds := useTestDataSafe(t)
+	tg := &telegram{key: "test"}
+	registry.ReportDeliveryQueued("test", "1", "Telegram", 3)
+	tg.Messages = []telegramMessage{{Target: 3, AnnouncementID: "1", Action: telegramActionSend}}
+
+	tg.reportDelivery(telegramMessage{Target: 1, AnnouncementID: "1", Action: telegramActionSend}, nil)
+	tg.reportDelivery(telegramMessage{Target: 1, AnnouncementID: "1", Action: telegramActionSend, Part: 1}, nil)
+	tg.reportDelivery(telegramMessage{Target: 2, AnnouncementID: "1", Action: telegramActionSend}, errors.New("test"))
+	tg.reportCollectedDelivery(false)
+
+	d, _ := ds.GetDeliveries("test", "1")
+	if len(d) != 1 || d[0].Sent != 0 || d[0].Failed != 0 {
+		t.Fatalf("delivery = %+v, want no progress while the announcement is still queued", d)
+	}
+
+	tg.delivery["1"].since = time.Now().Add(-telegramDeliveryInterval)
+	tg.reportCollectedDelivery(false)
+	d, _ = ds.GetDeliveries("test", "1")
+	if len(d) != 1 || d[0].Sent != 1 || d[0].Failed != 1 || d[0].State != registry.DeliveryQueued {
+		t.Fatalf("delivery = %+v, want 1 sent and 1 failed after the interval", d)
+	}
+
+	tg.Messages = nil
+	tg.reportDelivery(telegramMessage{Target: 3, AnnouncementID: "1", Action: telegramActionSend}, nil)
+	tg.reportCollectedDelivery(false)
+	d, _ = ds.GetDeliveries("test", "1")
+	if len(d) != 1 || d[0].Sent != 2 || d[0].State != registry.DeliveryFailed {
+		t.Fatalf("delivery = %+v, want finished delivery once nothing is queued", d)
+	}
+}
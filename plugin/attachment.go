@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bytes"
+
+	"github.com/Top-Ranger/announcementgo/registry"
+	"github.com/domodwyer/mailyak/v3"
+)
+
+// attachmentCache caches the content of attachments loaded from the data safe.
+// It is not save to use in parallel.
+type attachmentCache struct {
+	key  string
+	data map[string][]byte
+}
+
+func newAttachmentCache(key string) *attachmentCache {
+	return &attachmentCache{key: key, data: make(map[string][]byte)}
+}
+
+// get returns the content of the attachment, loading it from the data safe if needed.
+func (c *attachmentCache) get(a registry.Attachment) ([]byte, error) {
+	b, ok := c.data[a.ID]
+	if ok {
+		return b, nil
+	}
+	b, err := registry.CurrentDataSafe.GetAttachment(c.key, a.ID)
+	if err != nil {
+		return nil, err
+	}
+	c.data[a.ID] = b
+	return b, nil
+}
+
+// attachToMail adds all attachments to the mail.
+func (c *attachmentCache) attachToMail(mail *mailyak.MailYak, attachments []registry.Attachment) error {
+	for i := range attachments {
+		b, err := c.get(attachments[i])
+		if err != nil {
+			return err
+		}
+		mail.AttachWithMimeType(attachments[i].Name, bytes.NewReader(b), attachments[i].ContentType)
+	}
+	return nil
+}
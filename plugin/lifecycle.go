@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"sync"
+)
+
+// lifecycle manages the background workers of a plugin.
+// The zero value is ready to use. stop can be called even if start was never called.
+type lifecycle struct {
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// start runs all workers in the background until ctx is done or stop is called.
+func (l *lifecycle) start(ctx context.Context, workers ...func(ctx context.Context)) {
+	ctx, l.cancel = context.WithCancel(ctx)
+	for i := range workers {
+		l.wg.Add(1)
+		go func(worker func(ctx context.Context)) {
+			defer l.wg.Done()
+			worker(ctx)
+		}(workers[i])
+	}
+}
+
+// stop signals all workers to end and runs cleanup after all workers have returned.
+// It returns ctx.Err() if this does not finish before ctx is done. cleanup might be nil.
+func (l *lifecycle) stop(ctx context.Context, cleanup func()) error {
+	if l.cancel != nil {
+		l.cancel()
+	}
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		if cleanup != nil {
+			cleanup()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/Top-Ranger/announcementgo/helper"
+	"github.com/Top-Ranger/announcementgo/registry"
+	"github.com/Top-Ranger/announcementgo/translation"
+	"github.com/domodwyer/mailyak/v3"
+)
+
+// mailSubject returns the subject of a mail containing the announcement.
+// Urgent announcements are marked in the subject.
+func mailSubject(prefix string, a registry.Announcement) string {
+	parts := make([]string, 0, 3)
+	if prefix != "" {
+		parts = append(parts, prefix)
+	}
+	if a.Priority == registry.PriorityUrgent {
+		parts = append(parts, translation.GetDefaultTranslation().MailUrgentMarker)
+	}
+	parts = append(parts, a.Header)
+	return strings.Join(parts, " ")
+}
+
+// setMailPriority sets the headers used by mail clients to display the priority of a mail.
+// Nothing is set for normal priority.
+func setMailPriority(mail *mailyak.MailYak, priority registry.Priority) {
+	switch priority {
+	case registry.PriorityLow:
+		mail.AddHeader("Importance", "low")
+		mail.AddHeader("X-Priority", "5")
+	case registry.PriorityUrgent:
+		mail.AddHeader("Importance", "high")
+		mail.AddHeader("X-Priority", "1")
+	}
+}
+
+// mailPreview returns the preview of a mail containing the announcement.
+func mailPreview(prefix string, a registry.Announcement) template.HTML {
+	parts := []previewPart{{Title: "Subject", Text: mailSubject(prefix, a)}}
+	switch a.Priority {
+	case registry.PriorityLow:
+		parts = append(parts, previewPart{Title: "Headers", Text: "Importance: low\nX-Priority: 5"})
+	case registry.PriorityUrgent:
+		parts = append(parts, previewPart{Title: "Headers", Text: "Importance: high\nX-Priority: 1"})
+	}
+	parts = append(parts, previewPart{Title: "Plain text", Text: a.Message}, previewPart{Title: "HTML", HTML: helper.Format([]byte(a.Message))})
+	if p, ok := attachmentPreview(a); ok {
+		parts = append(parts, p)
+	}
+	return renderPreview(parts)
+}
+
+// correctionMail returns the announcement marked as a correction.
+// All language variants are marked, since the marker must survive localisation.
+func correctionMail(a registry.Announcement) registry.Announcement {
+	tl := translation.GetDefaultTranslation()
+	return mapLanguages(a, func(header, message string) (string, string) {
+		return fmt.Sprintf("%s: %s", tl.AnnouncementCorrection, header), message
+	})
+}
+
+// retractionMail returns a notice that the announcement was retracted, including all language variants.
+// Attachments are removed.
+func retractionMail(a registry.Announcement) registry.Announcement {
+	tl := translation.GetDefaultTranslation()
+	a = mapLanguages(a, func(header, message string) (string, string) {
+		return fmt.Sprintf("%s: %s", tl.AnnouncementRetracted, header), strings.Join([]string{tl.AnnouncementRetractedText, "***", message}, "\n\n")
+	})
+	a.Attachments = nil
+	return a
+}
+
+// mapLanguages applies f to the header and message of the announcement and all its variants.
+// The variants of a are not changed.
+func mapLanguages(a registry.Announcement, f func(header, message string) (string, string)) registry.Announcement {
+	a.Header, a.Message = f(a.Header, a.Message)
+	if a.Variants != nil {
+		variants := make([]registry.Variant, len(a.Variants))
+		for i := range a.Variants {
+			variants[i] = a.Variants[i]
+			variants[i].Header, variants[i].Message = f(variants[i].Header, variants[i].Message)
+		}
+		a.Variants = variants
+	}
+	return a
+}
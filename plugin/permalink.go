@@ -0,0 +1,40 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Top-Ranger/announcementgo/registry"
+	"github.com/Top-Ranger/announcementgo/server"
+	"github.com/Top-Ranger/announcementgo/translation"
+)
+
+// permalinkURL returns the absolute URL of the permalink of the announcement.
+// The ID of the announcement must be set.
+func permalinkURL(key string, a registry.Announcement) string {
+	return strings.Join([]string{server.ServerURL(), a.Path(key)}, "")
+}
+
+// withPermalink returns the message followed by a link to read the announcement online.
+// The link is only added if the announcement can be read without login and the server URL is known.
+func withPermalink(key, message string, a registry.Announcement) string {
+	if a.ID == "" || !a.Retracted.IsZero() || !registry.IsPublic(key) || server.ServerURL() == "" {
+		return message
+	}
+	return strings.Join([]string{message, fmt.Sprintf("%s: %s", translation.GetDefaultTranslation().ReadOnline, permalinkURL(key, a))}, "\n\n")
+}
@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"strings"
+
+	"github.com/Top-Ranger/announcementgo/registry"
+)
+
+func init() {
+	var err error
+	previewTemplate, err = template.New("previewTemplate").Parse(previewSite)
+	if err != nil {
+		panic(err)
+	}
+}
+
+var previewTemplate *template.Template
+
+const previewSite = `
+{{range $i, $e := .}}
+<h3>{{$e.Title}}</h3>
+{{if $e.HTML}}
+<div{{if $e.PreserveLines}} class="announcement-display"{{end}}>{{$e.HTML}}</div>
+{{else if $e.Text}}
+<pre>{{$e.Text}}</pre>
+{{end}}
+{{end}}
+`
+
+// previewPart represents a single part of a plugin preview, e.g. a message or a mail part.
+// If HTML is set, it is shown rendered, otherwise Text is shown verbatim.
+// PreserveLines should be set if line breaks of HTML are shown by the receiving client.
+type previewPart struct {
+	Title         string
+	Text          string
+	HTML          template.HTML
+	PreserveLines bool
+}
+
+// renderPreview renders all parts of a preview.
+func renderPreview(parts []previewPart) template.HTML {
+	var buf bytes.Buffer
+	err := previewTemplate.Execute(&buf, parts)
+	if err != nil {
+		log.Printf("preview: %s", err.Error())
+	}
+	return template.HTML(buf.String())
+}
+
+// attachmentPreview returns a part listing all attachments of the announcement.
+// ok is false if the announcement has no attachments.
+func attachmentPreview(a registry.Announcement) (part previewPart, ok bool) {
+	if len(a.Attachments) == 0 {
+		return previewPart{}, false
+	}
+	names := make([]string, len(a.Attachments))
+	for i := range a.Attachments {
+		names[i] = a.Attachments[i].Name
+	}
+	return previewPart{Title: "Attachments", Text: strings.Join(names, "\n")}, true
+}
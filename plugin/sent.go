@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"time"
+)
+
+// sentRetention is the time after which plugins forget which messages they sent for an announcement.
+// Older announcements are no longer edited or deleted on external services.
+const sentRetention = 30 * 24 * time.Hour
+
+// pruneSent removes all announcements sent more than sentRetention before now from sent and sentTime.
+// Announcements without a recorded time (e.g. from older versions) are treated as sent now.
+func pruneSent[T any](sent map[string][]T, sentTime map[string]time.Time, now time.Time) {
+	for id := range sent {
+		t, ok := sentTime[id]
+		if !ok {
+			sentTime[id] = now
+			continue
+		}
+		if now.Sub(t) > sentRetention {
+			delete(sent, id)
+		}
+	}
+	for id := range sentTime {
+		if _, ok := sent[id]; !ok {
+			delete(sentTime, id)
+		}
+	}
+}
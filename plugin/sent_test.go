@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPruneSent(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	sent := map[string][]int{
+		"old":    {1},
+		"recent": {2},
+		"legacy": {3},
+	}
+	sentTime := map[string]time.Time{
+		"old":       now.Add(-sentRetention - time.Minute),
+		"recent":    now.Add(-time.Hour),
+		"forgotten": now.Add(-time.Hour),
+	}
+
+	pruneSent(sent, sentTime, now)
+
+	tests := []struct {
+		id       string
+		wantSent bool
+		wantTime time.Time
+	}{
+		{id: "old", wantSent: false},
+		{id: "recent", wantSent: true, wantTime: now.Add(-time.Hour)},
+		{id: "legacy", wantSent: true, wantTime: now},
+		{id: "forgotten", wantSent: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			_, ok := sent[tt.id]
+			if ok != tt.wantSent {
+				t.Fatalf("sent contains %s: %t, want %t", tt.id, ok, tt.wantSent)
+			}
+			st, ok := sentTime[tt.id]
+			if ok != tt.wantSent {
+				t.Fatalf("sentTime contains %s: %t, want %t", tt.id, ok, tt.wantSent)
+			}
+			if ok && !st.Equal(tt.wantTime) {
+				t.Errorf("sentTime[%s] = %s, want %s", tt.id, st, tt.wantTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Top-Ranger/announcementgo/counter"
+	"github.com/Top-Ranger/announcementgo/helper"
+	"github.com/Top-Ranger/announcementgo/registry"
+	"github.com/Top-Ranger/announcementgo/server"
+	"github.com/Top-Ranger/announcementgo/templates"
+	"github.com/Top-Ranger/announcementgo/translation"
+)
+
+// previewHandle renders the announcement contained in the publish form as it would be delivered by all selected plugins.
+// Nothing is saved or sent.
+func (a *announcement) previewHandle(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	loggedin, _ := server.GetLogin(a.Key, r)
+	if !loggedin {
+		rw.WriteHeader(http.StatusForbidden)
+		t := templates.TextTemplateStruct{Text: "403 Forbidden", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		t := templates.TextTemplateStruct{Text: "405 Method Not Allowed", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+
+	counter.StartProcess()
+	defer counter.EndProcess()
+
+	r.Body = http.MaxBytesReader(rw, r.Body, int64(a.AttachmentMaxSize)*attachmentMaxNumber+10<<20)
+	err := r.ParseMultipartForm(1 << 20)
+	if err != nil && err != http.ErrNotMultipart {
+		rw.WriteHeader(http.StatusBadRequest)
+		t := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+
+	an := registry.Announcement{
+		Header:     r.Form.Get("subject"),
+		Message:    r.Form.Get("message"),
+		Time:       time.Now(),
+		Author:     server.GetIdentity(a.Key, r),
+		Variants:   readVariants(r),
+		Categories: registry.FilterCategories(a.Key, r.Form["category"]),
+		Plugins:    a.selectedPlugins(r.Form["plugin"]),
+	}
+	an.Priority, err = registry.ParsePriority(r.Form.Get("priority"))
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		t := templates.TextTemplateStruct{Text: "400 Bad Request", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+	uploads, err := a.readAttachments(r)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		t := templates.TextTemplateStruct{Text: template.HTML(template.HTMLEscapeString(fmt.Sprintf("400 Bad Request: %s", err.Error()))), Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+	for i := range uploads {
+		an.Attachments = append(an.Attachments, uploads[i].attachment)
+	}
+	an = a.sign(an)
+
+	languages := []string{translation.GetDefaultTranslation().Language}
+	for i := range an.Variants {
+		languages = append(languages, an.Variants[i].Language)
+	}
+
+	td := templates.PreviewTemplateStruct{
+		Key:              a.Key,
+		ShortDescription: a.ShortDescription,
+		Translation:      translation.GetDefaultTranslation(),
+		Previews:         make([]templates.LanguagePreview, 0, len(languages)),
+	}
+	for l := range languages {
+		la := an.Localised(languages[l])
+		p := templates.LanguagePreview{
+			Language: languages[l],
+			Header:   la.Header,
+			Web:      helper.Format([]byte(la.Message)),
+		}
+		for i := range a.plugins {
+			if la.SentTo(a.pluginNames[i]) {
+				p.Plugins = append(p.Plugins, templates.PluginPreview{Name: a.pluginNames[i], Preview: a.plugins[i].Preview(la)})
+			}
+		}
+		td.Previews = append(td.Previews, p)
+	}
+
+	err = templates.PreviewTemplate.Execute(rw, td)
+	if err != nil {
+		log.Printf("announcement preview template (%s): %s", a.Key, err.Error())
+	}
+}
@@ -0,0 +1,220 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // Ensure time zones are available for recurring announcements
+
+	"github.com/Top-Ranger/announcementgo/registry"
+	"github.com/Top-Ranger/announcementgo/templates"
+)
+
+// cronSchedule represents a parsed cron expression (minute hour day-of-month month day-of-week).
+type cronSchedule struct {
+	minute, hour, dom, month, dow map[int]bool
+	domRestricted, dowRestricted  bool
+	location                      *time.Location
+}
+
+// parseCron parses a cron expression with five fields in the given time zone.
+// Each field supports '*', single values, ranges ('1-5'), lists ('1,3') and steps ('*/15').
+// Sunday is represented as 0 or 7 in the day-of-week field.
+func parseCron(expression, location string) (cronSchedule, error) {
+	c := cronSchedule{}
+	var err error
+
+	if location == "" {
+		location = "Local"
+	}
+	c.location, err = time.LoadLocation(location)
+	if err != nil {
+		return cronSchedule{}, err
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return cronSchedule{}, fmt.Errorf("cron expression must have 5 fields, got %d", len(fields))
+	}
+
+	c.minute, err = parseCronField(fields[0], 0, 59)
+	if err != nil {
+		return cronSchedule{}, fmt.Errorf("minute: %w", err)
+	}
+	c.hour, err = parseCronField(fields[1], 0, 23)
+	if err != nil {
+		return cronSchedule{}, fmt.Errorf("hour: %w", err)
+	}
+	c.dom, err = parseCronField(fields[2], 1, 31)
+	if err != nil {
+		return cronSchedule{}, fmt.Errorf("day of month: %w", err)
+	}
+	c.month, err = parseCronField(fields[3], 1, 12)
+	if err != nil {
+		return cronSchedule{}, fmt.Errorf("month: %w", err)
+	}
+	c.dow, err = parseCronField(fields[4], 0, 7)
+	if err != nil {
+		return cronSchedule{}, fmt.Errorf("day of week: %w", err)
+	}
+	if c.dow[7] {
+		c.dow[0] = true
+	}
+	c.domRestricted = !strings.HasPrefix(fields[2], "*")
+	c.dowRestricted = !strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	result := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		stepped := false
+		if i := strings.Index(part, "/"); i != -1 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			if step <= 0 {
+				return nil, fmt.Errorf("invalid step %d", step)
+			}
+			part = part[:i]
+			stepped = true
+		}
+
+		start, end := min, max
+		switch {
+		case part == "*":
+			// Use full range
+		case strings.Contains(part, "-"):
+			split := strings.SplitN(part, "-", 2)
+			var err error
+			start, err = strconv.Atoi(split[0])
+			if err != nil {
+				return nil, err
+			}
+			end, err = strconv.Atoi(split[1])
+			if err != nil {
+				return nil, err
+			}
+		default:
+			var err error
+			start, err = strconv.Atoi(part)
+			if err != nil {
+				return nil, err
+			}
+			end = start
+			if stepped {
+				// A single value with a step is the start of a range (e.g. 5/15 means 5-59/15)
+				end = max
+			}
+		}
+
+		if start < min || end > max || start > end {
+			return nil, fmt.Errorf("%s out of range %d-%d", part, min, max)
+		}
+		for v := start; v <= end; v += step {
+			result[v] = true
+		}
+	}
+	return result, nil
+}
+
+func (c cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom[t.Day()]
+	dow := c.dow[int(t.Weekday())]
+	if c.domRestricted && c.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// next returns the first time after the given time which matches the schedule.
+// It returns the zero time if no such time can be found within the next five years.
+func (c cronSchedule) next(after time.Time) time.Time {
+	t := after.In(c.location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !c.month[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.location)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.location)
+			continue
+		}
+		if !c.hour[t.Hour()] {
+			n := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, c.location)
+			if !n.After(t) {
+				// Can happen when the clock is turned back
+				n = t.Truncate(time.Hour).Add(time.Hour)
+			}
+			t = n
+			continue
+		}
+		if !c.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// nextRecurringRun calculates the next run of the recurring announcement after the given time.
+func nextRecurringRun(r templates.RecurringAnnouncement, after time.Time) (time.Time, error) {
+	c, err := parseCron(r.Schedule, r.Location)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := c.next(after)
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("schedule '%s' never matches", r.Schedule)
+	}
+	return next, nil
+}
+
+func (a *announcement) processRecurring(now time.Time) []registry.Announcement {
+	// Caller needs to lock
+	due := make([]registry.Announcement, 0)
+	changed := false
+	for i := range a.recurring {
+		if a.recurring[i].Paused || now.Before(a.recurring[i].NextRun) {
+			continue
+		}
+		due = append(due, registry.Announcement{Header: a.recurring[i].Header, Message: a.recurring[i].Message, Author: a.recurring[i].Author})
+		changed = true
+
+		// Calculate from now so that runs missed during downtime are not all sent at once
+		next, err := nextRecurringRun(a.recurring[i], now)
+		if err != nil {
+			log.Printf("recurring announcement (%s): %s", a.Key, err.Error())
+			a.addMessage(fmt.Sprintf("%s: %s", a.recurring[i].Header, err.Error()), true)
+			a.recurring[i].Paused = true
+			continue
+		}
+		a.recurring[i].NextRun = next
+	}
+	if changed {
+		a.saveInternal("recurring", &a.recurring)
+	}
+	return due
+}
@@ -0,0 +1,189 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronField(t *testing.T) {
+	tests := []struct {
+		name    string
+		field   string
+		min     int
+		max     int
+		want    []int
+		wantErr bool
+	}{
+		{name: "single", field: "5", min: 0, max: 59, want: []int{5}},
+		{name: "all", field: "*", min: 1, max: 3, want: []int{1, 2, 3}},
+		{name: "range", field: "2-4", min: 0, max: 6, want: []int{2, 3, 4}},
+		{name: "list", field: "1,3,5", min: 0, max: 6, want: []int{1, 3, 5}},
+		{name: "step", field: "*/15", min: 0, max: 59, want: []int{0, 15, 30, 45}},
+		{name: "single with step", field: "5/15", min: 0, max: 59, want: []int{5, 20, 35, 50}},
+		{name: "range with step", field: "10-20/5", min: 0, max: 59, want: []int{10, 15, 20}},
+		{name: "list with range", field: "1,4-5", min: 0, max: 6, want: []int{1, 4, 5}},
+		{name: "below range", field: "0", min: 1, max: 31, wantErr: true},
+		{name: "above range", field: "60", min: 0, max: 59, wantErr: true},
+		{name: "inverted range", field: "5-2", min: 0, max: 6, wantErr: true},
+		{name: "zero step", field: "*/0", min: 0, max: 59, wantErr: true},
+		{name: "negative step", field: "*/-1", min: 0, max: 59, wantErr: true},
+		{name: "not a number", field: "a", min: 0, max: 59, wantErr: true},
+		{name: "empty", field: "", min: 0, max: 59, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCronField(tt.field, tt.min, tt.max)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseCronField(%q) = %v, want error", tt.field, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCronField(%q): %s", tt.field, err.Error())
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseCronField(%q) = %v, want %v", tt.field, got, tt.want)
+			}
+			for _, v := range tt.want {
+				if !got[v] {
+					t.Errorf("parseCronField(%q) = %v, missing %d", tt.field, got, v)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		location   string
+		wantErr    bool
+	}{
+		{name: "every minute", expression: "* * * * *"},
+		{name: "weekdays", expression: "30 8 * * 1-5", location: "Europe/Berlin"},
+		{name: "sunday as 7", expression: "0 0 * * 7"},
+		{name: "too few fields", expression: "* * * *", wantErr: true},
+		{name: "too many fields", expression: "* * * * * *", wantErr: true},
+		{name: "invalid hour", expression: "0 24 * * *", wantErr: true},
+		{name: "invalid month", expression: "0 0 1 13 *", wantErr: true},
+		{name: "unknown location", expression: "* * * * *", location: "Nowhere/Unknown", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseCron(tt.expression, tt.location)
+			if tt.wantErr && err == nil {
+				t.Errorf("parseCron(%q, %q) returned no error", tt.expression, tt.location)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("parseCron(%q, %q): %s", tt.expression, tt.location, err.Error())
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		after      time.Time
+		want       time.Time
+	}{
+		{
+			name:       "next minute",
+			expression: "* * * * *",
+			after:      time.Date(2026, 3, 10, 12, 30, 15, 0, berlin),
+			want:       time.Date(2026, 3, 10, 12, 31, 0, 0, berlin),
+		},
+		{
+			name:       "exact time is not included",
+			expression: "30 12 * * *",
+			after:      time.Date(2026, 3, 10, 12, 30, 0, 0, berlin),
+			want:       time.Date(2026, 3, 11, 12, 30, 0, 0, berlin),
+		},
+		{
+			name:       "weekday skips weekend",
+			expression: "0 8 * * 1-5",
+			after:      time.Date(2026, 3, 13, 9, 0, 0, 0, berlin), // Friday
+			want:       time.Date(2026, 3, 16, 8, 0, 0, 0, berlin), // Monday
+		},
+		{
+			name:       "sunday as 7",
+			expression: "0 0 * * 7",
+			after:      time.Date(2026, 3, 10, 0, 0, 0, 0, berlin), // Tuesday
+			want:       time.Date(2026, 3, 15, 0, 0, 0, 0, berlin),
+		},
+		{
+			name:       "day of month or day of week",
+			expression: "0 0 20 * 1",
+			after:      time.Date(2026, 3, 10, 0, 0, 0, 0, berlin),
+			want:       time.Date(2026, 3, 16, 0, 0, 0, 0, berlin), // Monday before the 20th
+		},
+		{
+			name:       "day of month with step and day of week",
+			expression: "0 0 */2 * 1",
+			after:      time.Date(2026, 3, 10, 0, 0, 0, 0, berlin), // Tuesday
+			want:       time.Date(2026, 3, 23, 0, 0, 0, 0, berlin), // Monday on an odd day, both fields must match
+		},
+		{
+			name:       "next month",
+			expression: "0 0 1 * *",
+			after:      time.Date(2026, 12, 15, 0, 0, 0, 0, berlin),
+			want:       time.Date(2027, 1, 1, 0, 0, 0, 0, berlin),
+		},
+		{
+			name:       "leap day",
+			expression: "0 0 29 2 *",
+			after:      time.Date(2026, 3, 1, 0, 0, 0, 0, berlin),
+			want:       time.Date(2028, 2, 29, 0, 0, 0, 0, berlin),
+		},
+		{
+			name:       "daylight saving time gap",
+			expression: "30 2 * * *",
+			after:      time.Date(2026, 3, 28, 12, 0, 0, 0, berlin),
+			want:       time.Date(2026, 3, 30, 2, 30, 0, 0, berlin), // 2:30 does not exist on 2026-03-29
+		},
+		{
+			name:       "never",
+			expression: "0 0 31 2 *",
+			after:      time.Date(2026, 3, 1, 0, 0, 0, 0, berlin),
+			want:       time.Time{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseCron(tt.expression, "Europe/Berlin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := c.next(tt.after)
+			if !got.Equal(tt.want) {
+				t.Errorf("next(%s) of %q = %s, want %s", tt.after, tt.expression, got, tt.want)
+			}
+		})
+	}
+}
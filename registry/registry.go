@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020,2021 Marcus Soll
+// Copyright 2020,2021,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -18,9 +18,13 @@
 package registry
 
 import (
+	"context"
 	"fmt"
 	"html/template"
+	"log"
 	"net/http"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 )
@@ -43,22 +47,250 @@ type PluginFactory func(key, shortDescription string, errorChannel chan string)
 
 // Plugin represents an announcement plugin.
 // All methods must be save to use in parallel.
+// Preview returns how the announcement would be delivered by the plugin without sending anything.
+// Settings returns the current configuration of the plugin. It is used to record configuration changes, so secrets like passwords must be marked.
+// Start is called once after creation and starts all background work of the plugin, which runs until ctx is done or Stop is called.
+// Stop ends all background work, persists queued messages and disconnects from external services.
+// It must return once ctx is done and return an error if the plugin could not stop in time.
 type Plugin interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
 	GetConfig() template.HTML
 	ProcessConfigChange(r *http.Request) error
+	Settings() []Setting
+	Preview(a Announcement) template.HTML
 	NewAnnouncement(a Announcement, id string)
+	UpdateAnnouncement(a Announcement, id string)
+	RetractAnnouncement(a Announcement, id string)
+	ExpireAnnouncement(a Announcement, id string)
+}
+
+// QueuedPlugin can optionally be implemented by plugins which send messages through a queue.
+// Queued returns the number of messages waiting in the queue. It must be callable in parallel to all other methods.
+type QueuedPlugin interface {
+	Queued() int
+}
+
+// Setting represents a single configuration value of a plugin.
+// The value of secret settings is never shown.
+type Setting struct {
+	Name   string
+	Value  string
+	Secret bool
+}
+
+// AuditEntry represents a single entry of the audit log of a key.
+// Role is "admin", "user" or empty if the person was not logged in. Identity contains the display name of the person if known.
+// Details contains a human readable description of the action, e.g. the changed settings.
+type AuditEntry struct {
+	Time     time.Time
+	Role     string
+	Identity string
+	IP       string
+	Action   string
+	Details  string
+}
+
+// DeliveryState represents the state of the delivery of an announcement through a plugin.
+type DeliveryState int
+
+// All possible delivery states.
+const (
+	DeliveryQueued DeliveryState = iota
+	DeliverySent
+	DeliveryFailed
+	DeliveryRetrying
+)
+
+// String returns the name of the delivery state.
+func (s DeliveryState) String() string {
+	switch s {
+	case DeliveryQueued:
+		return "queued"
+	case DeliverySent:
+		return "sent"
+	case DeliveryFailed:
+		return "failed"
+	case DeliveryRetrying:
+		return "retrying"
+	default:
+		return "unknown"
+	}
+}
+
+// Delivery represents the delivery of a single announcement through a plugin.
+// Recipients is the number of recipients the announcement is delivered to (e.g. mail addresses or chats), Sent and Failed count the finished deliveries.
+// Error contains the last error reported by the plugin.
+type Delivery struct {
+	Plugin     string
+	State      DeliveryState
+	Recipients int
+	Sent       int
+	Failed     int
+	Error      string
+	Updated    time.Time
 }
 
 // Announcement represents a single announcement.
 // It has two main parts: a short header (something like a short summary) and the actual message.
 // Time contains the publication time of the announcement.
+// Edited contains the time of the last change of the announcement. It is zero if the announcement was never changed.
+// Retracted contains the time the announcement was retracted. It is zero if the announcement is not retracted.
+// Expires contains the time after which the announcement is no longer valid. It is zero if the announcement does not expire.
+// Variants contains the announcement in additional languages. Header and Message are in the default language.
+// Categories contains the categories of the announcement. Announcements without categories are meant for everyone.
+// Attachments contains all files attached to the announcement. The content of the files can be retrieved through the DataSafe.
+// Priority contains the priority of the announcement. Plugins should use it to decide how noticeable the announcement is.
+// Author contains the display name of the person who published the announcement. It is empty if the person is unknown.
+// Plugins contains the names of the plugins the announcement was sent to. It is nil for announcements which were sent to all plugins before the selection was recorded.
+// ID is set by the DataSafe when reading announcements and ignored when saving.
 type Announcement struct {
 	Header, Message string
 	Time            time.Time
+	Edited          time.Time
+	Retracted       time.Time
+	Expires         time.Time
+	Variants        []Variant
+	Categories      []string
+	Attachments     []Attachment
+	Priority        Priority
+	Author          string
+	Plugins         []string
+	ID              string
+}
+
+// Priority represents the priority of an announcement.
+// The zero value is the normal priority.
+type Priority int
+
+// All known priorities.
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityUrgent Priority = 1
+)
+
+// String returns the name of the priority as used in forms.
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityUrgent:
+		return "urgent"
+	default:
+		return "normal"
+	}
+}
+
+// ParsePriority returns the priority with the given name.
+// An empty name results in the normal priority.
+func ParsePriority(name string) (Priority, error) {
+	switch name {
+	case "low":
+		return PriorityLow, nil
+	case "normal", "":
+		return PriorityNormal, nil
+	case "urgent":
+		return PriorityUrgent, nil
+	}
+	return PriorityNormal, fmt.Errorf("unknown priority %s", name)
+}
+
+// Variant represents an announcement in a different language.
+// Language holds the language code as used by the translation package.
+type Variant struct {
+	Language        string
+	Header, Message string
+}
+
+// Variant returns the variant of the announcement in the given language.
+// It returns an empty Variant if there is none.
+func (a Announcement) Variant(language string) Variant {
+	for i := range a.Variants {
+		if a.Variants[i].Language == language {
+			return a.Variants[i]
+		}
+	}
+	return Variant{}
+}
+
+// Localised returns the announcement with header and message in the given language.
+// Regional codes like 'de-AT' are matched to the language. If there is no variant in the language, the announcement is returned unchanged.
+func (a Announcement) Localised(language string) Announcement {
+	language, _, _ = strings.Cut(strings.ToLower(language), "-")
+	language, _, _ = strings.Cut(language, "_")
+	v := a.Variant(language)
+	if v.Language == "" {
+		return a
+	}
+	a.Header, a.Message = v.Header, v.Message
+	return a
+}
+
+// Attachment represents a file attached to an announcement.
+// ID is returned by DataSafe.SaveAttachment, Size is in bytes.
+type Attachment struct {
+	ID          string
+	Name        string
+	ContentType string
+	Size        int
+}
+
+// Path returns the path under which the attachment can be downloaded, relative to the server URL.
+func (a Attachment) Path(key string) string {
+	return fmt.Sprintf("/%s/attachment/%s/%s", key, url.PathEscape(a.ID), url.PathEscape(a.Name))
+}
+
+// Path returns the path of the permalink of the announcement, relative to the server URL.
+// The ID of the announcement must be set.
+func (a Announcement) Path(key string) string {
+	return fmt.Sprintf("/%s/a/%s", key, url.PathEscape(a.ID))
+}
+
+// SentTo returns whether the announcement was sent to the plugin with the given name.
+func (a Announcement) SentTo(plugin string) bool {
+	if a.Plugins == nil {
+		return true
+	}
+	for i := range a.Plugins {
+		if a.Plugins[i] == plugin {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesCategories returns whether the announcement should be delivered to a recipient who selected the given categories.
+// Recipients without a selection get all announcements, announcements without categories are delivered to all recipients.
+func (a Announcement) MatchesCategories(selection []string) bool {
+	if len(selection) == 0 || len(a.Categories) == 0 {
+		return true
+	}
+	for i := range a.Categories {
+		for j := range selection {
+			if a.Categories[i] == selection[j] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Expired returns whether the announcement has expired.
+func (a Announcement) Expired() bool {
+	return !a.Expires.IsZero() && !time.Now().Before(a.Expires)
 }
 
 // DataSafe represents a backend for save storage of questionnaire results.
 // The keys of the announcement should be kept in the order they arrive.
+// UpdateAnnouncement must keep the replaced version, which can be retrieved through GetAnnouncementRevisions (oldest first).
+// RetractAnnouncement marks an announcement as retracted. Retracted announcements are still returned by all methods.
+// SaveAttachment saves the content of a file. The returned id must be hard to guess, since attachments might be accessible without login.
+// AddAuditEntry appends an entry to the audit log of the key, GetAuditEntries returns all entries of the audit log matching the query (oldest first).
+// SaveDelivery saves the delivery record of an announcement, replacing the record of the same plugin. GetDeliveries returns all delivery records of an announcement.
+// GetLastAnnouncements returns the newest n announcements, GetAnnouncementsSince returns all announcements saved after the one with the given id (an empty id returns all announcements). Both return the announcements oldest first.
+// CountAnnouncements returns the number of announcements. All three should not need to read all announcements.
+// SearchAnnouncements returns a single page of all announcements matching the query (newest first) as well as the total number of matching announcements.
 type DataSafe interface {
 	InitialiseDatasafe(config []byte) error
 	GetConfig(key, plugin string) ([]byte, error)
@@ -66,7 +298,59 @@ type DataSafe interface {
 	SaveAnnouncement(key string, a Announcement) (id string, err error)
 	GetAnnouncement(key, id string) (Announcement, error)
 	GetAllAnnouncements(key string) ([]Announcement, error)
+	GetLastAnnouncements(key string, n int) ([]Announcement, error)
+	GetAnnouncementsSince(key, id string) ([]Announcement, error)
+	CountAnnouncements(key string) (int, error)
 	GetAnnouncementKeys(key string) ([]string, error)
+	UpdateAnnouncement(key, id string, a Announcement) error
+	GetAnnouncementRevisions(key, id string) ([]Announcement, error)
+	SearchAnnouncements(key string, q AnnouncementQuery) (result []Announcement, total int, err error)
+	RetractAnnouncement(key, id string, t time.Time) error
+	SaveAttachment(key string, data []byte) (id string, err error)
+	GetAttachment(key, id string) ([]byte, error)
+	AddAuditEntry(key string, e AuditEntry) error
+	GetAuditEntries(key string, q AuditQuery) ([]AuditEntry, error)
+	SaveDelivery(key, id string, d Delivery) error
+	GetDeliveries(key, id string) ([]Delivery, error)
+}
+
+// AnnouncementQuery represents a search for announcements.
+// Text is searched case insensitive in the header and message of the announcements. An empty text matches all announcements.
+// From and To restrict the publication time to From <= Time < To. Zero times are not used for restricting.
+// If HideRetracted is true, retracted announcements are not part of the result.
+// If HideExpired is true, announcements which expired before now are not part of the result.
+// Offset and Limit select the page of the result. A Limit of 0 returns all remaining announcements.
+type AnnouncementQuery struct {
+	Text          string
+	From          time.Time
+	To            time.Time
+	HideRetracted bool
+	HideExpired   bool
+	Offset        int
+	Limit         int
+}
+
+// AuditQuery restricts the entries of the audit log.
+// If Action is not empty, only entries with this action are returned.
+// From and To restrict the time of the entries to From <= Time < To. Zero times are not used for restricting.
+type AuditQuery struct {
+	Action string
+	From   time.Time
+	To     time.Time
+}
+
+// Matches returns whether the entry matches the query.
+func (q AuditQuery) Matches(e AuditEntry) bool {
+	if q.Action != "" && e.Action != q.Action {
+		return false
+	}
+	if !q.From.IsZero() && e.Time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !e.Time.Before(q.To) {
+		return false
+	}
+	return true
 }
 
 // PasswordMethod enables to compare the password against different 'truth'.
@@ -83,8 +367,160 @@ var (
 	knownDataSafesMutex       = sync.RWMutex{}
 	knownPasswordMethods      = make(map[string]PasswordMethod)
 	knownPasswordMethodsMutex = sync.RWMutex{}
+	categories                = make(map[string][]string)
+	categoriesMutex           = sync.RWMutex{}
+	publicKeys                = make(map[string]bool)
+	publicKeysMutex           = sync.RWMutex{}
+	deliveryMutexes           = make(map[string]*sync.Mutex)
+	deliveryMutexesMutex      = sync.Mutex{}
 )
 
+// ReportDeliveryQueued reports that the announcement with the given id is queued for delivery through the plugin.
+// recipients is the number of recipients if known or 0 otherwise. All previous progress of the plugin is reset.
+// You can savely use it in parallel.
+func ReportDeliveryQueued(key, id, plugin string, recipients int) {
+	updateDelivery(key, id, plugin, func(d *Delivery) {
+		*d = Delivery{Plugin: plugin, State: DeliveryQueued, Recipients: recipients}
+	})
+}
+
+// ReportDeliveryProgress reports that the announcement was sent to or failed for further recipients.
+// err is the reason for failed deliveries and might be nil.
+// The delivery is finished once all recipients are processed. It is failed if any recipient failed.
+// You can savely use it in parallel.
+func ReportDeliveryProgress(key, id, plugin string, sent, failed int, err error) {
+	updateDelivery(key, id, plugin, func(d *Delivery) {
+		d.Sent += sent
+		d.Failed += failed
+		if err != nil {
+			d.Error = err.Error()
+		}
+		if d.Recipients < d.Sent+d.Failed {
+			d.Recipients = d.Sent + d.Failed
+		}
+		if d.Sent+d.Failed >= d.Recipients {
+			d.State = DeliverySent
+			if d.Failed > 0 {
+				d.State = DeliveryFailed
+			}
+		}
+	})
+}
+
+// ReportDeliveryRetrying reports that the delivery failed for at least one recipient, but will be tried again.
+// You can savely use it in parallel.
+func ReportDeliveryRetrying(key, id, plugin string, err error) {
+	updateDelivery(key, id, plugin, func(d *Delivery) {
+		d.State = DeliveryRetrying
+		d.Error = err.Error()
+	})
+}
+
+// ReportDeliveryFailed reports that the announcement could not be delivered to any of the remaining recipients.
+// You can savely use it in parallel.
+func ReportDeliveryFailed(key, id, plugin string, err error) {
+	updateDelivery(key, id, plugin, func(d *Delivery) {
+		if remaining := d.Recipients - d.Sent - d.Failed; remaining > 0 {
+			d.Failed += remaining
+		}
+		d.State = DeliveryFailed
+		d.Error = err.Error()
+	})
+}
+
+// updateDelivery applies f to the delivery record of the plugin and saves it.
+// Delivery is only tracked for announcements with an id. Errors are only logged, since the delivery itself is not affected.
+func updateDelivery(key, id, plugin string, f func(d *Delivery)) {
+	if id == "" || CurrentDataSafe == nil {
+		return
+	}
+
+	m := deliveryMutex(key)
+	m.Lock()
+	defer m.Unlock()
+
+	deliveries, err := CurrentDataSafe.GetDeliveries(key, id)
+	if err != nil {
+		log.Printf("delivery (%s): %s", key, err.Error())
+		return
+	}
+	d := Delivery{Plugin: plugin}
+	for i := range deliveries {
+		if deliveries[i].Plugin == plugin {
+			d = deliveries[i]
+			break
+		}
+	}
+	f(&d)
+	d.Plugin = plugin
+	d.Updated = time.Now()
+	err = CurrentDataSafe.SaveDelivery(key, id, d)
+	if err != nil {
+		log.Printf("delivery (%s): %s", key, err.Error())
+	}
+}
+
+// deliveryMutex returns the mutex guarding the delivery records of the key.
+func deliveryMutex(key string) *sync.Mutex {
+	deliveryMutexesMutex.Lock()
+	defer deliveryMutexesMutex.Unlock()
+	m, ok := deliveryMutexes[key]
+	if !ok {
+		m = new(sync.Mutex)
+		deliveryMutexes[key] = m
+	}
+	return m
+}
+
+// SetPublic sets whether the announcements of a key can be read without login.
+// You can savely use it in parallel.
+func SetPublic(key string, public bool) {
+	publicKeysMutex.Lock()
+	defer publicKeysMutex.Unlock()
+	publicKeys[key] = public
+}
+
+// IsPublic returns whether the announcements of a key can be read without login.
+// You can savely use it in parallel.
+func IsPublic(key string) bool {
+	publicKeysMutex.RLock()
+	defer publicKeysMutex.RUnlock()
+	return publicKeys[key]
+}
+
+// SetCategories sets the categories available for a key.
+// You can savely use it in parallel.
+func SetCategories(key string, c []string) {
+	categoriesMutex.Lock()
+	defer categoriesMutex.Unlock()
+	categories[key] = append([]string{}, c...)
+}
+
+// GetCategories returns the categories available for a key.
+// You can savely use it in parallel.
+func GetCategories(key string) []string {
+	categoriesMutex.RLock()
+	defer categoriesMutex.RUnlock()
+	return append([]string{}, categories[key]...)
+}
+
+// FilterCategories returns all categories of the selection which are available for the key, in the order of the key.
+// You can savely use it in parallel.
+func FilterCategories(key string, selection []string) []string {
+	categoriesMutex.RLock()
+	defer categoriesMutex.RUnlock()
+	result := make([]string, 0, len(selection))
+	for i := range categories[key] {
+		for j := range selection {
+			if categories[key][i] == selection[j] {
+				result = append(result, categories[key][i])
+				break
+			}
+		}
+	}
+	return result
+}
+
 // RegisterPlugin registeres a plugin.
 // The name of the plugin is used as an identifier and must be unique.
 // You can savely use it in parallel.
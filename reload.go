@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/Top-Ranger/announcementgo/server"
+	"github.com/Top-Ranger/announcementgo/templates"
+	"github.com/Top-Ranger/announcementgo/translation"
+)
+
+// reloadAnnouncements reloads the configuration of all announcements.
+// Errors are logged. On errors, the announcements keep their old configuration if possible.
+func reloadAnnouncements() error {
+	log.Println("main: reloading announcements")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout())
+	defer cancel()
+
+	err := LoadAnnouncements(ctx, config.PathConfig)
+	if err != nil {
+		log.Println("main: reloading announcements failed:", err)
+		return err
+	}
+	log.Println("main: announcements reloaded")
+	return nil
+}
+
+// reloadHandle reloads the configuration of all announcements. It can only be used by admins.
+func (a *announcement) reloadHandle(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if r.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		t := templates.TextTemplateStruct{Text: "405 Method Not Allowed", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+
+	_, admin := server.GetLogin(a.Key, r)
+	if !admin {
+		rw.WriteHeader(http.StatusForbidden)
+		t := templates.TextTemplateStruct{Text: "403 Forbidden", Translation: translation.GetDefaultTranslation()}
+		templates.TextTemplate.Execute(rw, t)
+		return
+	}
+
+	// The announcement might be replaced during reloading, so the audit entry is written first
+	a.audit(r, "reload", "")
+	writeReloadResult(rw, reloadAnnouncements(), a.Key, false)
+}
+
+// writeReloadResult shows the result of reloading together with a link to back.
+// Details of the error are only shown if showError is true since they might contain information about other keys.
+func writeReloadResult(rw http.ResponseWriter, err error, back string, showError bool) {
+	tl := translation.GetDefaultTranslation()
+	text := template.HTMLEscapeString(tl.ConfigurationReloaded)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		text = template.HTMLEscapeString(tl.ConfigurationReloadFailed)
+		if showError {
+			text = fmt.Sprintf("%s<br><br>%s", text, template.HTMLEscapeString(err.Error()))
+		}
+	}
+	t := templates.TextTemplateStruct{Text: template.HTML(fmt.Sprintf("%s<br><br><a href=\"/%s\">%s</a>", text, template.HTMLEscapeString(back), template.HTMLEscapeString(tl.Back))), Translation: tl}
+	templates.TextTemplate.Execute(rw, t)
+}
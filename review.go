@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Top-Ranger/announcementgo/counter"
+	"github.com/Top-Ranger/announcementgo/helper"
+	"github.com/Top-Ranger/announcementgo/registry"
+	"github.com/Top-Ranger/announcementgo/templates"
+	"github.com/Top-Ranger/announcementgo/translation"
+)
+
+// reviewKeepResolved is the number of approved or rejected submissions which are kept so that users can see their status.
+const reviewKeepResolved = 25
+
+// errUnknownSubmission is returned if no pending submission with the id exists.
+var errUnknownSubmission = errors.New("unknown submission")
+
+// submit adds an announcement to the review queue.
+// Caller needs to lock.
+func (a *announcement) submit(an registry.Announcement, publishTime time.Time) error {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	id, err := helper.NewID()
+	if err != nil {
+		return err
+	}
+	a.review = append(a.review, templates.Submission{
+		ID:           id,
+		Announcement: an,
+		PublishTime:  publishTime,
+		Submitted:    time.Now(),
+		Status:       templates.SubmissionPending,
+	})
+	a.saveInternal("review", &a.review)
+	a.addMessage(translation.GetDefaultTranslation().AnnouncementSubmitted, false)
+	return nil
+}
+
+// approve approves a pending submission with the (possibly edited) header and message.
+// If the submission should be published later, it is scheduled. Otherwise, the returned announcement must be published by the caller after releasing the lock.
+// Caller needs to lock.
+func (a *announcement) approve(id, header, message string) (registry.Announcement, bool, error) {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	for i := range a.review {
+		if a.review[i].ID != id || a.review[i].Status != templates.SubmissionPending {
+			continue
+		}
+		an := a.review[i].Announcement
+		an.Header = header
+		an.Message = message
+		an.Time = time.Now()
+		publishTime := a.review[i].PublishTime
+		publish := !publishTime.After(an.Time)
+		if !publish {
+			err := a.schedule(an, publishTime)
+			if err != nil {
+				return registry.Announcement{}, false, err
+			}
+		}
+		a.review[i].Status = templates.SubmissionApproved
+		a.review[i].Announcement.Header = header
+		a.review[i].Announcement.Message = message
+		a.trimReview()
+		a.saveInternal("review", &a.review)
+		return an, publish, nil
+	}
+	return registry.Announcement{}, false, fmt.Errorf("%w %s", errUnknownSubmission, id)
+}
+
+// reject rejects a pending submission.
+// Caller needs to lock.
+func (a *announcement) reject(id, reason string) error {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	for i := range a.review {
+		if a.review[i].ID != id || a.review[i].Status != templates.SubmissionPending {
+			continue
+		}
+		a.review[i].Status = templates.SubmissionRejected
+		a.review[i].Reason = reason
+		a.trimReview()
+		a.saveInternal("review", &a.review)
+		a.addMessage(translation.GetDefaultTranslation().SubmissionWasRejected, false)
+		return nil
+	}
+	return fmt.Errorf("%w %s", errUnknownSubmission, id)
+}
+
+// trimReview removes the oldest resolved submissions so that at most reviewKeepResolved remain.
+// Caller needs to lock.
+func (a *announcement) trimReview() {
+	resolved := 0
+	for i := range a.review {
+		if a.review[i].Status != templates.SubmissionPending {
+			resolved++
+		}
+	}
+	if resolved <= reviewKeepResolved {
+		return
+	}
+	remove := resolved - reviewKeepResolved
+	review := make([]templates.Submission, 0, len(a.review)-remove)
+	for i := range a.review {
+		if remove > 0 && a.review[i].Status != templates.SubmissionPending {
+			remove--
+			continue
+		}
+		review = append(review, a.review[i])
+	}
+	a.review = review
+}
+
+// visibleSubmissions returns the submissions the viewer may see, newest first.
+// Administrators see all submissions, other users only the ones submitted under their identity.
+// Caller needs to lock.
+func (a *announcement) visibleSubmissions(admin bool, identity string) []templates.Submission {
+	submissions := make([]templates.Submission, 0, len(a.review))
+	for i := len(a.review) - 1; i >= 0; i-- {
+		if !admin && a.review[i].Announcement.Author != identity {
+			continue
+		}
+		submissions = append(submissions, a.review[i])
+	}
+	return submissions
+}
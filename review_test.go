@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/Top-Ranger/announcementgo/registry"
+	"github.com/Top-Ranger/announcementgo/templates"
+)
+
+func TestVisibleSubmissions(t *testing.T) {
+	a := &announcement{review: []templates.Submission{
+		{ID: "1", Announcement: registry.Announcement{Author: "alice"}},
+		{ID: "2", Announcement: registry.Announcement{Author: "bob"}},
+		{ID: "3", Announcement: registry.Announcement{Author: "alice"}},
+	}}
+
+	tests := []struct {
+		name     string
+		admin    bool
+		identity string
+		want     []string
+	}{
+		{name: "admin", admin: true, identity: "carol", want: []string{"3", "2", "1"}},
+		{name: "own submissions", identity: "alice", want: []string{"3", "1"}},
+		{name: "no submissions", identity: "carol", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := a.visibleSubmissions(tt.admin, tt.identity)
+			if len(got) != len(tt.want) {
+				t.Fatalf("visibleSubmissions(%t, %q) returned %d submissions, want %v", tt.admin, tt.identity, len(got), tt.want)
+			}
+			for i := range tt.want {
+				if got[i].ID != tt.want[i] {
+					t.Errorf("visibleSubmissions(%t, %q)[%d] = %s, want %s", tt.admin, tt.identity, i, got[i].ID, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/Top-Ranger/announcementgo/counter"
+	"github.com/Top-Ranger/announcementgo/helper"
+	"github.com/Top-Ranger/announcementgo/registry"
+	"github.com/Top-Ranger/announcementgo/templates"
+	"github.com/Top-Ranger/announcementgo/translation"
+)
+
+// scheduleTimeFormat is the format used by datetime-local inputs.
+const scheduleTimeFormat = "2006-01-02T15:04"
+
+// schedule adds an announcement which will be published at publishTime.
+// Caller needs to lock.
+func (a *announcement) schedule(an registry.Announcement, publishTime time.Time) error {
+	counter.StartProcess()
+	defer counter.EndProcess()
+	id, err := helper.NewID()
+	if err != nil {
+		return err
+	}
+	a.scheduled = append(a.scheduled, templates.ScheduledAnnouncement{ID: id, Announcement: an, PublishTime: publishTime})
+	a.saveInternal("scheduled", &a.scheduled)
+	a.addMessage(translation.GetDefaultTranslation().AnnouncementScheduled, false)
+	return nil
+}
+
+func scheduleWorker(ctx context.Context, a *announcement) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(30 * time.Second):
+		}
+
+		counter.StartProcess()
+		a.l.Lock()
+		now := time.Now()
+		due := make([]templates.ScheduledAnnouncement, 0)
+		pending := make([]templates.ScheduledAnnouncement, 0, len(a.scheduled))
+		for i := range a.scheduled {
+			if now.Before(a.scheduled[i].PublishTime) {
+				pending = append(pending, a.scheduled[i])
+				continue
+			}
+			due = append(due, a.scheduled[i])
+		}
+		if len(due) != 0 {
+			a.scheduled = pending
+			a.saveInternal("scheduled", &a.scheduled)
+		}
+		recurring := a.processRecurring(now)
+		expired := a.processExpiry(now)
+		a.l.Unlock()
+
+		for i := range due {
+			an := due[i].Announcement
+			an.Time = time.Now()
+			a.publish(an)
+		}
+		for i := range recurring {
+			recurring[i].Time = time.Now()
+			a.publish(recurring[i])
+		}
+		for i := range expired {
+			a.expire(expired[i])
+		}
+		counter.EndProcess()
+	}
+}
@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// router dispatches requests to handlers which can be added and removed at runtime.
+// Patterns ending with a '/' match all paths starting with the pattern, all other patterns only match exactly.
+// The longest matching pattern is used. It is save to use in parallel.
+type router struct {
+	m      sync.RWMutex
+	routes map[string]http.HandlerFunc
+}
+
+// add adds a handler for the pattern. It returns an error if the pattern is already in use.
+func (r *router) add(pattern string, h http.HandlerFunc) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if r.routes == nil {
+		r.routes = make(map[string]http.HandlerFunc)
+	}
+	if _, ok := r.routes[pattern]; ok {
+		return fmt.Errorf("server: handle %s already registered", pattern)
+	}
+	r.routes[pattern] = h
+	return nil
+}
+
+// remove removes the handler of the pattern. Unknown patterns are ignored.
+func (r *router) remove(pattern string) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	delete(r.routes, pattern)
+}
+
+// removeAll removes all handlers of patterns which are either equal to base or start with base followed by a '/'.
+func (r *router) removeAll(base string) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	prefix := strings.Join([]string{base, ""}, "/")
+	for p := range r.routes {
+		if p == base || strings.HasPrefix(p, prefix) {
+			delete(r.routes, p)
+		}
+	}
+}
+
+// lookup returns the handler for the path.
+// The bool indicates whether a handler was found. You can only use the handler if the bool is true.
+func (r *router) lookup(path string) (http.HandlerFunc, bool) {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	if h, ok := r.routes[path]; ok {
+		return h, true
+	}
+	var best string
+	for p := range r.routes {
+		if strings.HasSuffix(p, "/") && strings.HasPrefix(path, p) && len(p) > len(best) {
+			best = p
+		}
+	}
+	if best == "" {
+		return nil, false
+	}
+	return r.routes[best], true
+}
@@ -0,0 +1,146 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// namedHandler returns a handler which writes name as the response.
+func namedHandler(name string) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte(name))
+	}
+}
+
+// routeName returns the name written by the handler found for path or an empty string if there is no handler.
+func routeName(r *router, path string) string {
+	h, ok := r.lookup(path)
+	if !ok {
+		return ""
+	}
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec.Body.String()
+}
+
+func TestRouterLookup(t *testing.T) {
+	var r router
+	for _, p := range []string{"/key", "/key/history.html", "/key/a/", "/key/a/special", "/other/"} {
+		err := r.add(p, namedHandler(p))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/key", want: "/key"},
+		{path: "/key/history.html", want: "/key/history.html"},
+		{path: "/key/a/1", want: "/key/a/"},
+		{path: "/key/a/", want: "/key/a/"},
+		{path: "/key/a/special", want: "/key/a/special"},
+		{path: "/key/a/special/1", want: "/key/a/"},
+		{path: "/other/deep/path", want: "/other/"},
+		{path: "/key/", want: ""},
+		{path: "/key/unknown", want: ""},
+		{path: "/keyword", want: ""},
+		{path: "/other", want: ""},
+		{path: "/", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got := routeName(&r, tt.path)
+			if got != tt.want {
+				t.Errorf("lookup(%q) used %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouterAdd(t *testing.T) {
+	var r router
+	err := r.add("/key", namedHandler("first"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.add("/key", namedHandler("second"))
+	if err == nil {
+		t.Error("adding a pattern twice returned no error")
+	}
+	if got := routeName(&r, "/key"); got != "first" {
+		t.Errorf("lookup(\"/key\") used %q, want \"first\"", got)
+	}
+}
+
+func TestRouterRemove(t *testing.T) {
+	var r router
+	r.remove("/unknown")
+	for _, p := range []string{"/key", "/key/history.html", "/key/a/", "/keyword", "/keyword/history.html"} {
+		err := r.add(p, namedHandler(p))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r.remove("/key/history.html")
+	if got := routeName(&r, "/key/history.html"); got != "" {
+		t.Errorf("removed pattern still used %q", got)
+	}
+	if got := routeName(&r, "/key"); got != "/key" {
+		t.Errorf("lookup(\"/key\") used %q after removing other pattern", got)
+	}
+
+	r.removeAll("/key")
+	for _, p := range []string{"/key", "/key/a/1"} {
+		if got := routeName(&r, p); got != "" {
+			t.Errorf("lookup(%q) used %q after removing all patterns of the key", p, got)
+		}
+	}
+	for _, p := range []string{"/keyword", "/keyword/history.html"} {
+		if got := routeName(&r, p); got != p {
+			t.Errorf("lookup(%q) used %q, patterns of other keys must not be removed", p, got)
+		}
+	}
+
+	err := r.add("/key", namedHandler("new"))
+	if err != nil {
+		t.Errorf("adding a removed pattern again: %s", err.Error())
+	}
+}
+
+func TestHandlePath(t *testing.T) {
+	tests := []struct {
+		key, handle, want string
+	}{
+		{key: "key", handle: "", want: "/key"},
+		{key: "/key/", handle: "", want: "/key"},
+		{key: "key", handle: "history.html", want: "/key/history.html"},
+		{key: "key", handle: "/a/", want: "/key/a/"},
+	}
+
+	for _, tt := range tests {
+		got := handlePath(tt.key, tt.handle)
+		if got != tt.want {
+			t.Errorf("handlePath(%q, %q) = %q, want %q", tt.key, tt.handle, got, tt.want)
+		}
+	}
+}
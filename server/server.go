@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020,2021,2022 Marcus Soll
+// Copyright 2020,2021,2022,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -19,6 +19,7 @@ import (
 	"bytes"
 	"context"
 	"embed"
+	"encoding/base64"
 	"fmt"
 	"html/template"
 	"io"
@@ -50,13 +51,18 @@ var cachedFiles embed.FS
 var etagCompare string
 var cookieTime = 60
 var cookieSecure = false
+var serverURL = ""
 
-var robottxt = []byte(`User-agent: *
-Disallow: /`)
+var robotsAllowed []string
+var robotsMutex sync.RWMutex
+
+var keyRouter router
 
 // Config holds all server configuration.
+// ServerURL is the public URL under which the server can be reached (e.g. https://example.com/announcements).
 type Config struct {
 	Address          string
+	ServerURL        string
 	PathDSGVO        string
 	PathImpressum    string
 	CookieTimeMinute int
@@ -68,8 +74,15 @@ func ConfigCookieSecure(secure bool) {
 	cookieSecure = secure
 }
 
+// ServerURL returns the public URL of the server without a trailing slash.
+// It might be empty if no URL is configured.
+func ServerURL() string {
+	return serverURL
+}
+
 // SetLoginCookie creates a valid login cookie for the given key.
-func SetLoginCookie(key string, admin bool, rw http.ResponseWriter, r *http.Request) error {
+// identity is the display name of the person logging in. It might be empty if the password has no name.
+func SetLoginCookie(key string, admin bool, identity string, rw http.ResponseWriter, r *http.Request) error {
 	name := fmt.Sprintf("%s#user", key)
 	if admin {
 		name = fmt.Sprintf("%s#admin", key)
@@ -78,33 +91,90 @@ func SetLoginCookie(key string, admin bool, rw http.ResponseWriter, r *http.Requ
 	if err != nil {
 		return err
 	}
+	// The path is set explicitly so the login also works if the cookie is set outside of the key
+	path := strings.Join([]string{"", key}, "/")
 	cookie := http.Cookie{}
 	cookie.Name = name
+	cookie.Path = path
 	cookie.Value = auth
 	cookie.MaxAge = 60 * cookieTime
 	cookie.SameSite = http.SameSiteLaxMode
 	cookie.HttpOnly = true
 	cookie.Secure = cookieSecure
 	http.SetCookie(rw, &cookie)
+
+	cookie = http.Cookie{}
+	cookie.Name = fmt.Sprintf("%s#identity", key)
+	cookie.Path = path
+	cookie.MaxAge = -1
+	if identity != "" {
+		// The identity is signed together with the key so it can not be changed by the user
+		encoded := base64.RawURLEncoding.EncodeToString([]byte(identity))
+		auth, err = data.GetStringsTimed(time.Now(), strings.Join([]string{cookie.Name, encoded}, "#"))
+		if err != nil {
+			return err
+		}
+		cookie.Value = strings.Join([]string{encoded, auth}, ":")
+		cookie.MaxAge = 60 * cookieTime
+	}
+	cookie.SameSite = http.SameSiteLaxMode
+	cookie.HttpOnly = true
+	cookie.Secure = cookieSecure
+	http.SetCookie(rw, &cookie)
 	return nil
 }
 
 // RemoveLoginCookie removes all cookies for the given key.
 // Please note that if the user can recreate the cookies on his machine, he can still log in.
 func RemoveLoginCookie(key string, rw http.ResponseWriter, r *http.Request) {
+	path := strings.Join([]string{"", key}, "/")
 	cookie := http.Cookie{}
 	cookie.Name = fmt.Sprintf("%s#admin", key)
+	cookie.Path = path
 	cookie.Value = ""
 	cookie.MaxAge = -1
 	http.SetCookie(rw, &cookie)
 
 	cookie = http.Cookie{}
 	cookie.Name = fmt.Sprintf("%s#user", key)
+	cookie.Path = path
+	cookie.Value = ""
+	cookie.MaxAge = -1
+	http.SetCookie(rw, &cookie)
+
+	cookie = http.Cookie{}
+	cookie.Name = fmt.Sprintf("%s#identity", key)
+	cookie.Path = path
 	cookie.Value = ""
 	cookie.MaxAge = -1
 	http.SetCookie(rw, &cookie)
 }
 
+// GetIdentity returns the display name of the person logged in for the given key.
+// It is empty if the person is not logged in or the password has no name.
+func GetIdentity(key string, r *http.Request) string {
+	loggedin, _ := GetLogin(key, r)
+	if !loggedin {
+		return ""
+	}
+	c, err := r.Cookie(fmt.Sprintf("%s#identity", key))
+	if err != nil {
+		return ""
+	}
+	encoded, auth, ok := strings.Cut(c.Value, ":")
+	if !ok {
+		return ""
+	}
+	if !data.VerifyStringsTimed(auth, strings.Join([]string{c.Name, encoded}, "#"), time.Now(), time.Duration(cookieTime)*time.Minute) {
+		return ""
+	}
+	identity, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return ""
+	}
+	return string(identity)
+}
+
 // GetLogin returns whether the user has a valid login and whether he is administrator.
 func GetLogin(key string, r *http.Request) (loggedin, admin bool) {
 	c := r.Cookies()
@@ -139,6 +209,7 @@ func InitialiseServer(config Config) error {
 	}
 
 	cookieTime = config.CookieTimeMinute
+	serverURL = strings.TrimSuffix(config.ServerURL, "/")
 
 	// Guard to only initialise once
 	serverInitialised := true
@@ -247,7 +318,13 @@ func InitialiseServer(config Config) error {
 
 	// robots.txt
 	http.HandleFunc("/robots.txt", func(rw http.ResponseWriter, r *http.Request) {
-		rw.Write(robottxt)
+		robotsMutex.RLock()
+		defer robotsMutex.RUnlock()
+		fmt.Fprintln(rw, "User-agent: *")
+		for i := range robotsAllowed {
+			fmt.Fprintf(rw, "Allow: %s\n", robotsAllowed[i])
+		}
+		fmt.Fprint(rw, "Disallow: /")
 	})
 
 	http.HandleFunc("/", rootHandle)
@@ -255,6 +332,10 @@ func InitialiseServer(config Config) error {
 }
 
 func rootHandle(rw http.ResponseWriter, r *http.Request) {
+	if h, ok := keyRouter.lookup(r.URL.Path); ok {
+		h(rw, r)
+		return
+	}
 	if r.URL.Path == "/" {
 		tl := translation.GetDefaultTranslation()
 		t := templates.TextTemplateStruct{Text: template.HTML("AnnouncementGo!"), Translation: tl}
@@ -316,8 +397,32 @@ func StopServer() {
 	}
 }
 
+// AllowRobots allows robots to access the path, overriding the global disallow in robots.txt.
+// The path is relative to the server root and must start with a '/'.
+// You can savely use it in parallel.
+func AllowRobots(path string) {
+	robotsMutex.Lock()
+	defer robotsMutex.Unlock()
+	robotsAllowed = append(robotsAllowed, path)
+}
+
+// DisallowRobots removes a path previously allowed through AllowRobots.
+// You can savely use it in parallel.
+func DisallowRobots(path string) {
+	robotsMutex.Lock()
+	defer robotsMutex.Unlock()
+	allowed := make([]string, 0, len(robotsAllowed))
+	for i := range robotsAllowed {
+		if robotsAllowed[i] != path {
+			allowed = append(allowed, robotsAllowed[i])
+		}
+	}
+	robotsAllowed = allowed
+}
+
 // AddHandle adds a hanler to the server.
 // It can be called by plugins and similar.
+// A handle ending with a '/' handles all paths starting with it. It returns an error if the handle is already registered.
 func AddHandle(key, handle string, h http.HandlerFunc) error {
 	serverMutex.Lock()
 	defer serverMutex.Unlock()
@@ -328,15 +433,28 @@ func AddHandle(key, handle string, h http.HandlerFunc) error {
 		return fmt.Errorf("server: not initialised")
 	}
 
+	return keyRouter.add(handlePath(key, handle), h)
+}
+
+// RemoveHandle removes a handler added through AddHandle.
+// Unknown handles are ignored.
+func RemoveHandle(key, handle string) {
+	keyRouter.remove(handlePath(key, handle))
+}
+
+// RemoveHandles removes all handlers of the key, including the handlers added by plugins.
+func RemoveHandles(key string) {
+	keyRouter.removeAll(handlePath(key, ""))
+}
+
+// handlePath returns the path of a handle of a key.
+func handlePath(key, handle string) string {
 	key = strings.TrimPrefix(key, "/")
 	key = strings.TrimSuffix(key, "/")
 	handle = strings.TrimPrefix(handle, "/")
 
 	if handle == "" {
-		http.HandleFunc(strings.Join([]string{"", key}, "/"), h)
-		return nil
+		return strings.Join([]string{"", key}, "/")
 	}
-
-	http.HandleFunc(strings.Join([]string{"", key, handle}, "/"), h)
-	return nil
+	return strings.Join([]string{"", key, handle}, "/")
 }
@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package templates
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// MessageTemplate represents a reusable announcement.
+// Header and Message can contain placeholders like {{.Date}} which are filled in before publishing.
+type MessageTemplate struct {
+	ID              string
+	Name            string
+	Header, Message string
+}
+
+// Validate returns an error if header or message are no valid templates.
+func (m MessageTemplate) Validate() error {
+	_, err := m.parse()
+	return err
+}
+
+// Fields returns the names of all placeholders in the order of their first occurrence.
+func (m MessageTemplate) Fields() []string {
+	t, err := m.parse()
+	if err != nil {
+		return nil
+	}
+	fields := make([]string, 0)
+	known := make(map[string]bool)
+	for _, tree := range []*template.Template{t.Lookup("header"), t.Lookup("message")} {
+		if tree == nil || tree.Tree == nil {
+			continue
+		}
+		collectFields(tree.Tree.Root, func(name string) {
+			if !known[name] {
+				known[name] = true
+				fields = append(fields, name)
+			}
+		})
+	}
+	return fields
+}
+
+// Execute fills in all placeholders with the given values.
+// Placeholders without value are replaced by an empty string.
+func (m MessageTemplate) Execute(values map[string]string) (header, message string, err error) {
+	t, err := m.parse()
+	if err != nil {
+		return "", "", err
+	}
+	data := make(map[string]string, len(values))
+	for _, f := range m.Fields() {
+		data[f] = values[f]
+	}
+	var h, msg strings.Builder
+	err = t.ExecuteTemplate(&h, "header", data)
+	if err != nil {
+		return "", "", err
+	}
+	err = t.ExecuteTemplate(&msg, "message", data)
+	if err != nil {
+		return "", "", err
+	}
+	return h.String(), msg.String(), nil
+}
+
+func (m MessageTemplate) parse() (*template.Template, error) {
+	t := template.New("header").Option("missingkey=zero")
+	_, err := t.Parse(m.Header)
+	if err != nil {
+		return nil, fmt.Errorf("subject: %w", err)
+	}
+	_, err = t.New("message").Parse(m.Message)
+	if err != nil {
+		return nil, fmt.Errorf("message: %w", err)
+	}
+	return t, nil
+}
+
+// collectFields calls f for every field (like {{.Date}}) used in the node.
+func collectFields(node parse.Node, f func(string)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for i := range n.Nodes {
+			collectFields(n.Nodes[i], f)
+		}
+	case *parse.ActionNode:
+		collectFields(n.Pipe, f)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for i := range n.Cmds {
+			collectFields(n.Cmds[i], f)
+		}
+	case *parse.CommandNode:
+		for i := range n.Args {
+			collectFields(n.Args[i], f)
+		}
+	case *parse.FieldNode:
+		if len(n.Ident) != 0 {
+			f(n.Ident[0])
+		}
+	case *parse.IfNode:
+		collectFields(n.Pipe, f)
+		collectFields(n.List, f)
+		collectFields(n.ElseList, f)
+	case *parse.RangeNode:
+		collectFields(n.Pipe, f)
+		collectFields(n.List, f)
+		collectFields(n.ElseList, f)
+	case *parse.WithNode:
+		collectFields(n.Pipe, f)
+		collectFields(n.List, f)
+		collectFields(n.ElseList, f)
+	}
+}
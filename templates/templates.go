@@ -18,7 +18,9 @@ package templates
 import (
 	"embed"
 	"html/template"
+	"time"
 
+	"github.com/Top-Ranger/announcementgo/helper"
 	"github.com/Top-Ranger/announcementgo/registry"
 	"github.com/Top-Ranger/announcementgo/translation"
 )
@@ -38,6 +40,24 @@ var AnnouncementTemplate *template.Template
 // HistoryTemplate contains the template for the history page.
 var HistoryTemplate *template.Template
 
+// PreviewTemplate contains the template for the preview page.
+var PreviewTemplate *template.Template
+
+// AuditTemplate contains the template for the audit log.
+var AuditTemplate *template.Template
+
+// ArchiveTemplate contains the template for the public archive.
+var ArchiveTemplate *template.Template
+
+// PermalinkTemplate contains the template for a single announcement.
+var PermalinkTemplate *template.Template
+
+// DeliveryTemplate contains the template for the delivery status of an announcement.
+var DeliveryTemplate *template.Template
+
+// DashboardTemplate contains the template for the super-admin dashboard.
+var DashboardTemplate *template.Template
+
 // TextTemplateStruct is a simple struct for the text template.
 type TextTemplateStruct struct {
 	Text        template.HTML
@@ -45,10 +65,12 @@ type TextTemplateStruct struct {
 }
 
 // LoginTemplateStruct is a struct for the LoginTemplate.
+// PublicArchive is true if the announcements can be read without login.
 type LoginTemplateStruct struct {
 	Key              string
 	ShortDescription string
 	Translation      translation.Translation
+	PublicArchive    bool
 }
 
 // AnnouncementTemplateStruct is a struct for the AnnouncementTemplate.
@@ -62,6 +84,20 @@ type AnnouncementTemplateStruct struct {
 	NotLoaded            []string
 	EnableDeleteMessages bool
 	ShowErrors           bool
+	Scheduled            []ScheduledAnnouncement
+	Recurring            []RecurringAnnouncement
+	Drafts               []Draft
+	Draft                Draft
+	Submissions          []Submission
+	ReviewRequired       bool
+	Plugins              []string
+	Attachments          bool
+	AttachmentTypes      string
+	Categories           []string
+	Languages            []string
+	Templates            []MessageTemplate
+	Template             MessageTemplate
+	TemplateFields       []string
 }
 
 type AnnouncementMessage struct {
@@ -69,12 +105,192 @@ type AnnouncementMessage struct {
 	Error bool
 }
 
+// ScheduledAnnouncement represents an announcement which will be published at PublishTime.
+type ScheduledAnnouncement struct {
+	ID           string
+	Announcement registry.Announcement
+	PublishTime  time.Time
+}
+
+// Draft represents an announcement which is not yet finished.
+// Time contains the time the draft was last saved.
+type Draft struct {
+	ID              string
+	Header, Message string
+	Time            time.Time
+}
+
+// Possible values of Submission.Status.
+const (
+	SubmissionPending  = "pending"
+	SubmissionApproved = "approved"
+	SubmissionRejected = "rejected"
+)
+
+// Submission represents an announcement submitted by a user which needs approval by an admin.
+// PublishTime is zero if the announcement should be published directly after approval.
+// Reason optionally contains the reason for a rejection.
+type Submission struct {
+	ID           string
+	Announcement registry.Announcement
+	PublishTime  time.Time
+	Submitted    time.Time
+	Status       string
+	Reason       string
+}
+
+// RecurringAnnouncement represents an announcement which is published regularly.
+// Schedule holds a cron expression which is evaluated in the time zone Location.
+// NextRun is the time of the next publication, it is not updated while the announcement is paused.
+// Author holds the display name of the person who created the recurring announcement.
+type RecurringAnnouncement struct {
+	ID              string
+	Header, Message string
+	Author          string
+	Schedule        string
+	Location        string
+	Paused          bool
+	NextRun         time.Time
+}
+
 // HistoryTemplateStruct is a struct for the HistoryTemplate.
+// If Revisions is true, History contains all revisions of a single announcement.
+// Languages contains all languages besides the default language which can be used for variants.
+// Otherwise, History contains the current page of the search result. Total is the number of announcements found,
+// PreviousPage and NextPage contain the URLs of the neighbouring pages or are empty if there is no such page.
 type HistoryTemplateStruct struct {
 	Key              string
 	ShortDescription string
 	History          []registry.Announcement
 	Translation      translation.Translation
+	Admin            bool
+	Revisions        bool
+	Languages        []string
+	Plugins          []string
+	Search           HistorySearch
+	Total            int
+	Page             int
+	Pages            int
+	PreviousPage     string
+	NextPage         string
+}
+
+// HistorySearch represents the search of the history as entered in the form.
+// From and To are dates in the format 2006-01-02, empty values are not used for filtering.
+type HistorySearch struct {
+	Text string
+	From string
+	To   string
+}
+
+// PreviewTemplateStruct is a struct for the PreviewTemplate.
+// Previews contains one entry for the default language and each variant.
+type PreviewTemplateStruct struct {
+	Key              string
+	ShortDescription string
+	Translation      translation.Translation
+	Previews         []LanguagePreview
+}
+
+// LanguagePreview represents the preview of an announcement in a single language.
+// Web contains the announcement as shown in the web interface.
+type LanguagePreview struct {
+	Language string
+	Header   string
+	Web      template.HTML
+	Plugins  []PluginPreview
+}
+
+// PluginPreview represents the preview of a single plugin.
+type PluginPreview struct {
+	Name    string
+	Preview template.HTML
+}
+
+// AuditTemplateStruct is a struct for the AuditTemplate.
+// Entries contains the filtered audit log (newest first), Actions contains all actions which can be used in the filter.
+type AuditTemplateStruct struct {
+	Key              string
+	ShortDescription string
+	Translation      translation.Translation
+	Entries          []registry.AuditEntry
+	Actions          []string
+	Filter           AuditFilter
+}
+
+// AuditFilter represents the filter of the audit log as entered in the form.
+// From and To are dates in the format 2006-01-02, empty values are not used for filtering.
+type AuditFilter struct {
+	Action   string
+	Identity string
+	From     string
+	To       string
+}
+
+// ArchiveTemplateStruct is a struct for the ArchiveTemplate.
+// Announcements contains the current page of the archive. Robots is true if search engines may index the archive.
+// PreviousPage and NextPage contain the URLs of the neighbouring pages or are empty if there is no such page.
+type ArchiveTemplateStruct struct {
+	Key              string
+	ShortDescription string
+	Translation      translation.Translation
+	Announcements    []registry.Announcement
+	Robots           bool
+	Page             int
+	Pages            int
+	PreviousPage     string
+	NextPage         string
+}
+
+// PermalinkTemplateStruct is a struct for the PermalinkTemplate.
+// URL is the permalink of the announcement, Description a plain text excerpt of the message.
+type PermalinkTemplateStruct struct {
+	Key              string
+	ShortDescription string
+	Translation      translation.Translation
+	Announcement     registry.Announcement
+	URL              string
+	Description      string
+	Robots           bool
+	LoggedIn         bool
+	PublicArchive    bool
+}
+
+// DeliveryTemplateStruct is a struct for the DeliveryTemplate.
+// Deliveries contains the delivery records of all plugins. Errors are only shown if ShowErrors is true.
+type DeliveryTemplateStruct struct {
+	Key              string
+	ShortDescription string
+	Translation      translation.Translation
+	Announcement     registry.Announcement
+	Deliveries       []registry.Delivery
+	ShowErrors       bool
+}
+
+// DashboardTemplateStruct is a struct for the DashboardTemplate.
+// Identity is the display name of the super-admin. It might be empty.
+type DashboardTemplateStruct struct {
+	Translation translation.Translation
+	Identity    string
+	Keys        []DashboardKey
+}
+
+// DashboardKey represents the state of a single key on the dashboard.
+// Errors contains the most recent errors of the message log (newest first). LastPublished is zero if nothing was published.
+type DashboardKey struct {
+	Key              string
+	ShortDescription string
+	Plugins          []DashboardPlugin
+	Errors           []string
+	LastPublished    time.Time
+}
+
+// DashboardPlugin represents a plugin of a key on the dashboard.
+// NotLoaded contains the error if the plugin could not be loaded. Queued is -1 if the plugin does not queue messages.
+type DashboardPlugin struct {
+	Name      string
+	NotLoaded string
+	Queued    int
 }
 
 func init() {
@@ -95,6 +311,9 @@ func init() {
 		"even": func(i int) bool {
 			return i%2 == 0
 		},
+		"format": func(s string) template.HTML {
+			return helper.Format([]byte(s))
+		},
 	}
 
 	b, err = templateFiles.ReadFile("template/announcement.html")
@@ -114,4 +333,58 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+
+	b, err = templateFiles.ReadFile("template/preview.html")
+	if err != nil {
+		panic(err)
+	}
+	PreviewTemplate, err = template.New("preview").Funcs(funcMap).Parse(string(b))
+	if err != nil {
+		panic(err)
+	}
+
+	b, err = templateFiles.ReadFile("template/audit.html")
+	if err != nil {
+		panic(err)
+	}
+	AuditTemplate, err = template.New("audit").Funcs(funcMap).Parse(string(b))
+	if err != nil {
+		panic(err)
+	}
+
+	b, err = templateFiles.ReadFile("template/archive.html")
+	if err != nil {
+		panic(err)
+	}
+	ArchiveTemplate, err = template.New("archive").Funcs(funcMap).Parse(string(b))
+	if err != nil {
+		panic(err)
+	}
+
+	b, err = templateFiles.ReadFile("template/permalink.html")
+	if err != nil {
+		panic(err)
+	}
+	PermalinkTemplate, err = template.New("permalink").Funcs(funcMap).Parse(string(b))
+	if err != nil {
+		panic(err)
+	}
+
+	b, err = templateFiles.ReadFile("template/delivery.html")
+	if err != nil {
+		panic(err)
+	}
+	DeliveryTemplate, err = template.New("delivery").Funcs(funcMap).Parse(string(b))
+	if err != nil {
+		panic(err)
+	}
+
+	b, err = templateFiles.ReadFile("template/dashboard.html")
+	if err != nil {
+		panic(err)
+	}
+	DashboardTemplate, err = template.New("dashboard").Funcs(funcMap).Parse(string(b))
+	if err != nil {
+		panic(err)
+	}
 }
@@ -19,7 +19,9 @@ import (
 	"embed"
 	"encoding/json"
 	"log"
+	"path"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -61,6 +63,127 @@ type Translation struct {
 	RegisterMailEMail                  string
 	RegisterMailCaptcha                string
 	PluginsNotLoaded                   string
+	AnnouncementCorrection             string
+	AnnouncementUpdated                string
+	EditAnnouncement                   string
+	Edited                             string
+	Revisions                          string
+	AnnouncementRetracted              string
+	AnnouncementRetractedText          string
+	RetractAnnouncement                string
+	RetractAnnouncementConfirm         string
+	AnnouncementWasRetracted           string
+	PublishAt                          string
+	AnnouncementScheduled              string
+	ScheduledAnnouncements             string
+	UpdateScheduledAnnouncement        string
+	CancelScheduledAnnouncement        string
+	RecurringAnnouncements             string
+	RecurringAdd                       string
+	RecurringSchedule                  string
+	RecurringLocation                  string
+	RecurringNextRun                   string
+	RecurringPaused                    string
+	RecurringPause                     string
+	RecurringResume                    string
+	RecurringSkip                      string
+	RecurringEnd                       string
+	Drafts                             string
+	SaveDraft                          string
+	DeleteDraft                        string
+	AnnouncementSubmitted              string
+	SubmissionWasRejected              string
+	Submissions                        string
+	SubmissionPending                  string
+	SubmissionApproved                 string
+	SubmissionRejected                 string
+	SubmittedAt                        string
+	ApproveSubmission                  string
+	RejectSubmission                   string
+	RejectReason                       string
+	SubmitForReview                    string
+	ExpiresAt                          string
+	Expired                            string
+	SendTo                             string
+	SentTo                             string
+	Attachments                        string
+	Categories                         string
+	CategoryAdd                        string
+	CategoryDelete                     string
+	RegisterMailCategories             string
+	BotCategoriesNone                  string
+	BotCategoriesAvailable             string
+	BotCategoriesSelected              string
+	BotCategoriesAll                   string
+	BotCategoriesHelp                  string
+	BotCategoryUnknown                 string
+	Priority                           string
+	PriorityLow                        string
+	PriorityNormal                     string
+	PriorityUrgent                     string
+	MailUrgentMarker                   string
+	BotUrgentRole                      string
+	VariantLanguage                    string
+	VariantOptional                    string
+	RegisterMailLanguage               string
+	Preview                            string
+	PreviewWeb                         string
+	Templates                          string
+	TemplateUse                        string
+	TemplateFill                       string
+	TemplateName                       string
+	TemplateAdd                        string
+	TemplateUpdate                     string
+	TemplateDelete                     string
+	TemplateHelp                       string
+	Author                             string
+	AuditLog                           string
+	AuditAction                        string
+	AuditAll                           string
+	AuditIdentity                      string
+	AuditRole                          string
+	AuditIP                            string
+	DateFrom                           string
+	DateTo                             string
+	AuditFilter                        string
+	AuditExport                        string
+	AuditEmpty                         string
+	Search                             string
+	SearchText                         string
+	AnnouncementsFound                 string
+	Page                               string
+	PageOf                             string
+	PreviousPage                       string
+	NextPage                           string
+	Archive                            string
+	ReadOnline                         string
+	Permalink                          string
+	DeliveryStatus                     string
+	DeliveryPlugin                     string
+	DeliveryState                      string
+	DeliveryQueued                     string
+	DeliverySent                       string
+	DeliveryFailed                     string
+	DeliveryRetrying                   string
+	DeliveryRecipients                 string
+	DeliveryUpdated                    string
+	DeliveryError                      string
+	DeliveryEmpty                      string
+	ResendAnnouncement                 string
+	ResendAnnouncementText             string
+	AnnouncementResent                 string
+	ReloadConfiguration                string
+	ReloadConfigurationText            string
+	ConfigurationReloaded              string
+	ConfigurationReloadFailed          string
+	Dashboard                          string
+	DashboardLastPublished             string
+	DashboardNotLoaded                 string
+	DashboardQueued                    string
+	DashboardLoaded                    string
+	DashboardRecentErrors              string
+	DashboardEnter                     string
+	DashboardEmpty                     string
 }
 
 const defaultLanguage = "en"
@@ -119,6 +242,23 @@ func getSingleTranslation(language string) (Translation, error) {
 	return t, nil
 }
 
+// GetLanguages returns the codes of all available languages, sorted alphabetically.
+func GetLanguages() []string {
+	entries, err := translationJsons.ReadDir(".")
+	if err != nil {
+		log.Printf("Can not read languages: %s", err.Error())
+		return nil
+	}
+	languages := make([]string, 0, len(entries))
+	for i := range entries {
+		if path.Ext(entries[i].Name()) == ".json" {
+			languages = append(languages, strings.TrimSuffix(entries[i].Name(), ".json"))
+		}
+	}
+	sort.Strings(languages)
+	return languages
+}
+
 // SetDefaultTranslation sets the default language to the provided one.
 // Does nothing if it returns error != nil.
 func SetDefaultTranslation(language string) error {